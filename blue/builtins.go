@@ -1,6 +1,10 @@
 package blue
 
 import (
+    "math"
+    "math/big"
+    "strconv"
+    "unicode/utf8"
     "github.com/Magnus9/blue/errpkg"
     "github.com/Magnus9/blue/objects"
 )
@@ -9,6 +13,13 @@ var builtins map[string]objects.BlObject
 var blBuiltinMethods = []objects.BlGFunctionObject{
     objects.NewBlGFunction("len", builtinLen, objects.GFUNC_VARARGS),
     objects.NewBlGFunction("err", builtinErr, objects.GFUNC_VARARGS),
+    objects.NewBlGFunction("chr", builtinChr, objects.GFUNC_VARARGS),
+    objects.NewBlGFunction("sorted", builtinSorted,
+                           objects.GFUNC_VARARGS | objects.GFUNC_KEYWORDS),
+    objects.NewBlGFunction("round", builtinRound, objects.GFUNC_VARARGS),
+    objects.NewBlGFunction("floor", builtinFloor, objects.GFUNC_VARARGS),
+    objects.NewBlGFunction("ceil",  builtinCeil,  objects.GFUNC_VARARGS),
+    objects.NewBlGFunction("trunc", builtinTrunc, objects.GFUNC_VARARGS),
 }
 
 func builtinLen(obj objects.BlObject,
@@ -23,11 +34,185 @@ func builtinLen(obj objects.BlObject,
             return objects.NewBlInt(int64(seq.SqSize(arg)))
         }
     }
+    if mp := typeobj.Mapping; mp != nil {
+        if mp.MpSize != nil {
+            return objects.NewBlInt(int64(mp.MpSize(arg)))
+        }
+    }
     errpkg.SetErrmsg("'%s' object is not a sequence",
                      typeobj.Name)
     return nil
 }
 
+// The string of one character with the code point given.
+func builtinChr(obj objects.BlObject,
+                args ...objects.BlObject) objects.BlObject {
+    var num int64
+    if objects.BlParseArguments("i", args, &num) == -1 {
+        return nil
+    }
+    if num < 0 || num > utf8.MaxRune ||
+       num >= 0xd800 && num <= 0xdfff {
+        errpkg.SetErrmsg("chr() argument %d is not a code point",
+                         num)
+        return nil
+    }
+    return objects.NewBlString(string(rune(num)))
+}
+
+/*
+ * A new sorted list of the items of an iterable. Takes
+ * the same keywords as list.sort.
+ */
+func builtinSorted(obj objects.BlObject,
+                   args ...objects.BlObject) objects.BlObject {
+    var key, cmp, reverse objects.BlObject
+    args, ret := objects.BlParseKeywords(args, []string{"key",
+                                         "cmp", "reverse"}, &key,
+                                         &cmp, &reverse)
+    if ret == -1 {
+        return nil
+    }
+    var arg objects.BlObject
+    if objects.BlParseArguments("o", args, &arg) == -1 {
+        return nil
+    }
+    lobj := objects.BlListType.Init(&objects.BlListType, arg)
+    if lobj == nil {
+        return nil
+    }
+    if key == objects.BlNil {
+        key = nil
+    }
+    if cmp == objects.BlNil {
+        cmp = nil
+    }
+    rev := reverse != nil && blEvalCondition(reverse)
+    if lobj.(*objects.BlListObject).Sort(key, cmp, rev) == -1 {
+        return nil
+    }
+    return lobj
+}
+
+/*
+ * Round a number half to even. Without 'ndigits' the
+ * result is an int, with it the number keeps its type
+ * and is rounded to that many digits after the point,
+ * or to tens, hundreds and so on when it's negative.
+ */
+func builtinRound(obj objects.BlObject,
+                  args ...objects.BlObject) objects.BlObject {
+    var num objects.BlObject
+    var ndigits int64
+    if objects.BlParseArguments("o|i", args, &num, &ndigits) == -1 {
+        return nil
+    }
+    switch t := num.(type) {
+        case *objects.BlIntObject:
+            if len(args) < 2 || ndigits >= 0 {
+                return t
+            }
+            return blRoundInt(t, -ndigits)
+        case *objects.BlFloatObject:
+            value := t.Float()
+            if len(args) < 2 {
+                return blFloatToInt(math.RoundToEven(value))
+            }
+            return objects.NewBlFloat(blRoundFloat(value, ndigits))
+    }
+    errpkg.SetErrmsg("expected number, found '%s'",
+                     num.BlType().Name)
+    return nil
+}
+
+// Round an int half to even, to a multiple of 10**exp.
+func blRoundInt(iobj *objects.BlIntObject,
+                exp int64) objects.BlObject {
+    value := iobj.Big()
+    // 10**exp is more than twice as big, so it rounds to 0.
+    if exp > int64(value.BitLen()) {
+        return objects.NewBlInt(0)
+    }
+    unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(exp), nil)
+    quo, rem := new(big.Int).DivMod(value, unit, new(big.Int))
+    switch rem.Lsh(rem, 1).Cmp(unit) {
+        case 1:
+            quo.Add(quo, big.NewInt(1))
+        case 0:
+            if quo.Bit(0) == 1 {
+                quo.Add(quo, big.NewInt(1))
+            }
+    }
+    return objects.NewBlBigInt(quo.Mul(quo, unit))
+}
+
+/*
+ * Round a float half to even, to 'ndigits' digits after
+ * the point. The digits are those of the exact binary
+ * value, so 2.675 rounds down to 2.67.
+ */
+func blRoundFloat(value float64, ndigits int64) float64 {
+    if math.IsInf(value, 0) || math.IsNaN(value) || ndigits > 400 {
+        return value
+    }
+    if ndigits >= 0 {
+        str := strconv.FormatFloat(value, 'f', int(ndigits), 64)
+        value, _ = strconv.ParseFloat(str, 64)
+        return value
+    }
+    if ndigits < -308 {
+        return math.Copysign(0.0, value)
+    }
+    unit := math.Pow10(int(-ndigits))
+    return math.RoundToEven(value / unit) * unit
+}
+
+func blFloatToInt(value float64) objects.BlObject {
+    if iobj := objects.BlIntFromFloat(value); iobj != nil {
+        return iobj
+    }
+    return nil
+}
+
+/*
+ * Make an int of a number, floats are rounded by 'fn'
+ * first. Used by floor, ceil and trunc.
+ */
+func blIntegral(args []objects.BlObject,
+                fn func(float64) float64) objects.BlObject {
+    var num objects.BlObject
+    if objects.BlParseArguments("o", args, &num) == -1 {
+        return nil
+    }
+    switch t := num.(type) {
+        case *objects.BlIntObject:
+            return t
+        case *objects.BlFloatObject:
+            return blFloatToInt(fn(t.Float()))
+    }
+    errpkg.SetErrmsg("expected number, found '%s'",
+                     num.BlType().Name)
+    return nil
+}
+
+// The largest int not above a number.
+func builtinFloor(obj objects.BlObject,
+                  args ...objects.BlObject) objects.BlObject {
+    return blIntegral(args, math.Floor)
+}
+
+// The smallest int not below a number.
+func builtinCeil(obj objects.BlObject,
+                 args ...objects.BlObject) objects.BlObject {
+    return blIntegral(args, math.Ceil)
+}
+
+// A number with its fraction cut off, toward zero.
+func builtinTrunc(obj objects.BlObject,
+                  args ...objects.BlObject) objects.BlObject {
+    return blIntegral(args, math.Trunc)
+}
+
 /*
  * Since there is no exception handling at the
  * moment, this subroutine can be used to error
@@ -45,12 +230,20 @@ func builtinErr(obj objects.BlObject,
 
 func blInitBuiltins() {
     mod := blInitModule("builtins", blBuiltinMethods)
-    mod.Locals["string"] = &objects.BlStringType
-    mod.Locals["float" ] = &objects.BlFloatType
-    mod.Locals["list"  ] = &objects.BlListType
-    mod.Locals["file"  ] = &objects.BlFileType
-    mod.Locals["bool"  ] = &objects.BlBoolType
-    mod.Locals["int"   ] = &objects.BlIntType
-    mod.Locals["socket"] = &objects.BlSocketType
+    mod.Locals["string"   ] = &objects.BlStringType
+    mod.Locals["float"    ] = &objects.BlFloatType
+    mod.Locals["list"     ] = &objects.BlListType
+    mod.Locals["tuple"    ] = &objects.BlTupleType
+    mod.Locals["bytes"    ] = &objects.BlBytesType
+    mod.Locals["bytearray"] = &objects.BlByteArrayType
+    mod.Locals["map"      ] = &objects.BlMapType
+    mod.Locals["set"      ] = &objects.BlSetType
+    mod.Locals["file"     ] = &objects.BlFileType
+    mod.Locals["bool"     ] = &objects.BlBoolType
+    mod.Locals["int"      ] = &objects.BlIntType
+    mod.Locals["socket"   ] = &objects.BlSocketType
+    // So that the repr of a float always reads back.
+    mod.Locals["inf"      ] = objects.NewBlFloat(math.Inf(1))
+    mod.Locals["nan"      ] = objects.NewBlFloat(math.NaN())
     builtins = mod.Locals
 }
\ No newline at end of file
@@ -0,0 +1,341 @@
+package blue
+
+import (
+    "io"
+    "os"
+    "fmt"
+    "sort"
+    "bufio"
+    "strings"
+    "io/ioutil"
+    "text/tabwriter"
+    "html/template"
+    "github.com/Magnus9/blue/token"
+    "github.com/Magnus9/blue/interm"
+)
+
+/*
+ * Line coverage. Every statement of a file that is run
+ * is registered when the file starts, and counted each
+ * time a frame moves to it. A line is covered if one of
+ * the statements on it ran.
+ */
+type coverFile struct {
+    pathname string
+    // Execution counts of the statements, by line.
+    lines    map[int]int
+}
+
+type Coverage struct {
+    files []*coverFile
+    // The statements being counted and the files they are in.
+    stmts map[*interm.Node]*coverFile
+}
+
+// The active coverage, nil when not recording.
+var coverage *Coverage
+
+func StartCoverage() *Coverage {
+    coverage = &Coverage{
+        stmts: make(map[*interm.Node]*coverFile),
+    }
+    blSetTraceHook()
+    return coverage
+}
+
+func (c *Coverage) Stop() {
+    coverage = nil
+    blSetTraceHook()
+}
+
+/*
+ * Register the statements of a file that is about to
+ * run. Each file is only registered once, even if it is
+ * run again.
+ */
+func (c *Coverage) addFile(pathname string, root *interm.Node) {
+    for _, f := range c.files {
+        if f.pathname == pathname {
+            return
+        }
+    }
+    f := &coverFile{
+        pathname: pathname,
+        lines   : make(map[int]int),
+    }
+    c.files = append(c.files, f)
+    c.addStmts(f, root)
+}
+
+func (c *Coverage) addStmt(f *coverFile, node *interm.Node) {
+    if node.LineNum == 0 {
+        return
+    }
+    c.stmts[node] = f
+    if _, ok := f.lines[node.LineNum]; !ok {
+        f.lines[node.LineNum] = 0
+    }
+}
+
+func (c *Coverage) addStmts(f *coverFile, node *interm.Node) {
+    switch node.NodeType {
+        case token.FILE_INPUT, token.BLOCK, token.CLASSBLOCK:
+            for _, n := range node.Children {
+                c.addStmt(f, n)
+                c.addStmts(f, n)
+            }
+        case token.IF:
+            // Conditions of 'elif' run on lines of their own.
+            for i, n := range node.Children {
+                if n.NodeType == token.ELIF {
+                    c.addStmt(f, node.Children[i + 1])
+                }
+                c.addStmts(f, n)
+            }
+        case token.MAKE_FUNC, token.MAKE_CLASS, token.WHILE,
+             token.FOR:
+            for _, n := range node.Children {
+                c.addStmts(f, n)
+            }
+    }
+}
+
+func (c *Coverage) hit(node *interm.Node) {
+    if f, ok := c.stmts[node]; ok {
+        f.lines[node.LineNum]++
+    }
+}
+
+/*
+ * The number of lines with statements in a file and how
+ * many of them ran.
+ */
+func (f *coverFile) count() (covered, total int) {
+    for _, n := range f.lines {
+        if n > 0 {
+            covered++
+        }
+    }
+    return covered, len(f.lines)
+}
+
+func coverPercent(covered, total int) float64 {
+    if total == 0 {
+        return 100
+    }
+    return 100 * float64(covered) / float64(total)
+}
+
+func (c *Coverage) sortedFiles() []*coverFile {
+    files := append([]*coverFile{}, c.files...)
+    sort.Slice(files, func(i, j int) bool {
+        return files[i].pathname < files[j].pathname
+    })
+    return files
+}
+
+func (f *coverFile) sortedLines() []int {
+    lines := make([]int, 0, len(f.lines))
+    for line := range f.lines {
+        lines = append(lines, line)
+    }
+    sort.Ints(lines)
+    return lines
+}
+
+/*
+ * The files where less than 'min' percent of the lines
+ * are covered, with their percentages.
+ */
+func (c *Coverage) Below(min float64) ([]string, []float64) {
+    var names []string
+    var percents []float64
+    for _, f := range c.sortedFiles() {
+        if pct := coverPercent(f.count()); pct < min {
+            names = append(names, f.pathname)
+            percents = append(percents, pct)
+        }
+    }
+    return names, percents
+}
+
+// Percentage of the lines covered over every file.
+func (c *Coverage) Percent() float64 {
+    var covered, total int
+    for _, f := range c.files {
+        cov, tot := f.count()
+        covered += cov
+        total += tot
+    }
+    return coverPercent(covered, total)
+}
+
+/*
+ * Print the percentage of every file and the total,
+ * i.e "modules/getopt.bl  85.7% of 21 lines".
+ */
+func (c *Coverage) WriteSummary(w io.Writer) {
+    var covered, total int
+    tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+    for _, f := range c.sortedFiles() {
+        cov, tot := f.count()
+        covered += cov
+        total += tot
+        fmt.Fprintf(tw, "%s\t%5.1f%% of %d lines\n", f.pathname,
+                    coverPercent(cov, tot), tot)
+    }
+    fmt.Fprintf(tw, "total\t%5.1f%% of %d lines\n",
+                coverPercent(covered, total), total)
+    tw.Flush()
+}
+
+/*
+ * Write the coverage profile, one "pathname:line count"
+ * entry for every line with statements.
+ */
+func (c *Coverage) WriteProfile(w io.Writer) error {
+    bw := bufio.NewWriter(w)
+    fmt.Fprintln(bw, "mode: count")
+    for _, f := range c.sortedFiles() {
+        for _, line := range f.sortedLines() {
+            fmt.Fprintf(bw, "%s:%d %d\n", f.pathname, line,
+                        f.lines[line])
+        }
+    }
+    return bw.Flush()
+}
+
+type coverLine struct {
+    Num   int
+    Text  string
+    // Executions, -1 for lines without statements.
+    Count int
+}
+
+func (f *coverFile) source() []coverLine {
+    src, err := ioutil.ReadFile(f.pathname)
+    if err != nil {
+        return nil
+    }
+    text := strings.Split(strings.TrimRight(string(src), "\n"), "\n")
+    lines := make([]coverLine, len(text))
+    for i, str := range text {
+        count, ok := f.lines[i + 1]
+        if !ok {
+            count = -1
+        }
+        lines[i] = coverLine{i + 1, strings.TrimRight(str, "\r"),
+                             count}
+    }
+    return lines
+}
+
+/*
+ * Write every file with its lines annotated. Lines
+ * that never ran are marked with '!!!!'.
+ */
+func (c *Coverage) WriteText(w io.Writer) {
+    for _, f := range c.sortedFiles() {
+        cov, tot := f.count()
+        fmt.Fprintf(w, "%s: %.1f%% of %d lines\n", f.pathname,
+                    coverPercent(cov, tot), tot)
+        for _, line := range f.source() {
+            var mark string
+            switch {
+                case line.Count == 0:
+                    mark = "!!!!"
+                case line.Count > 0:
+                    mark = fmt.Sprint(line.Count)
+            }
+            fmt.Fprintf(w, "%6s %5d  %s\n", mark, line.Num,
+                        line.Text)
+        }
+        fmt.Fprintln(w)
+    }
+}
+
+var coverHTML = template.Must(template.New("cover").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>blue coverage</title>
+<style>
+body { font-family: sans-serif; }
+table.summary td { padding: 0 1em; }
+pre { font-size: 13px; }
+.run { background: #d8f5d8; }
+.missed { background: #f8d4d4; }
+.num { color: #888; }
+</style>
+</head>
+<body>
+<h1>Coverage {{printf "%.1f" .Percent}}%</h1>
+<table class="summary">
+{{range .Files}}<tr><td><a href="#{{.Pathname}}">{{.Pathname}}</a></td><td>{{printf "%.1f" .Percent}}%</td><td>{{.Total}} lines</td></tr>
+{{end}}</table>
+{{range .Files}}<h2 id="{{.Pathname}}">{{.Pathname}} {{printf "%.1f" .Percent}}%</h2>
+<pre>{{range .Lines}}<span class="{{if eq .Count 0}}missed{{else if gt .Count 0}}run{{end}}"><span class="num">{{printf "%5d" .Num}}</span>  {{.Text}}</span>
+{{end}}</pre>
+{{end}}</body>
+</html>
+`))
+
+func (c *Coverage) WriteHTML(w io.Writer) error {
+    type file struct {
+        Pathname string
+        Percent  float64
+        Total    int
+        Lines    []coverLine
+    }
+    data := struct {
+        Percent float64
+        Files   []file
+    }{Percent: c.Percent()}
+    for _, f := range c.sortedFiles() {
+        cov, tot := f.count()
+        data.Files = append(data.Files, file{
+            Pathname: f.pathname,
+            Percent : coverPercent(cov, tot),
+            Total   : tot,
+            Lines   : f.source(),
+        })
+    }
+    return coverHTML.Execute(w, data)
+}
+
+/*
+ * Write the profile to 'pathname' and the report to
+ * 'report' if it is set, as HTML if it ends in '.html'
+ * and as annotated text otherwise.
+ */
+func (c *Coverage) WriteFiles(pathname, report string) error {
+    if pathname != "" {
+        f, err := os.Create(pathname)
+        if err != nil {
+            return err
+        }
+        err = c.WriteProfile(f)
+        if cerr := f.Close(); err == nil {
+            err = cerr
+        }
+        if err != nil {
+            return err
+        }
+    }
+    if report == "" {
+        return nil
+    }
+    f, err := os.Create(report)
+    if err != nil {
+        return err
+    }
+    if strings.HasSuffix(report, ".html") {
+        err = c.WriteHTML(f)
+    } else {
+        c.WriteText(f)
+    }
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
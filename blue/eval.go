@@ -4,6 +4,7 @@ package blue
 import (
     "os"
     "fmt"
+    "strings"
     "github.com/Magnus9/blue/token"
     "github.com/Magnus9/blue/interm"
     "github.com/Magnus9/blue/errpkg"
@@ -61,17 +62,49 @@ func New(pathname string, root *interm.Node) *Eval {
  * An evaluation context equals a compiled file.
  */
 func (e *Eval) Run(globals map[string]objects.BlObject) {
+    if coverage != nil {
+        coverage.addFile(e.pathname, e.root)
+    }
+    // Builtins call back into whichever program is running.
+    prev := objects.BlCall
+    objects.BlCall = e.call
+    defer func() { objects.BlCall = prev }()
     e.evalCode(e.root, globals, nil, e.pathname,
-               "<main>")
+               "<main>", e.root.LineNum)
+}
+
+/*
+ * Run the program and hand back the value of its last
+ * statement, nil if it has none. Used by the REPL to look
+ * at the value of an expression instead of printing it.
+ */
+func (e *Eval) RunValue(
+globals map[string]objects.BlObject) objects.BlObject {
+    var ret objects.BlObject
+    prev := objects.BlCall
+    objects.BlCall = e.call
+    defer func() { objects.BlCall = prev }()
+    e.frame = objects.NewBlFrame(e.frame, globals, nil,
+                                 e.pathname, "<main>")
+    for _, n := range e.root.Children {
+        ret = e.exec(n)
+    }
+    e.frame = e.frame.Prev
+    return ret
 }
 
 func (e *Eval) evalCode(
 node *interm.Node,
 globals, locals map[string]objects.BlObject,
-pathname, name string) {
+pathname, name string, lineNum int) {
     e.frame = objects.NewBlFrame(e.frame, globals,
                                  locals, pathname,
                                  name)
+    if profiler != nil {
+        // Deferred, a return unwinds the frame with a panic.
+        profiler.enter(pathname, name, lineNum)
+        defer profiler.leave()
+    }
     e.exec(node)
     e.frame = e.frame.Prev
 }
@@ -92,12 +125,30 @@ func (e *Eval) buildLocals(
 f *objects.BlFunctionObject,
 args *interm.Node,
 self *objects.BlInstanceObject,
+) map[string]objects.BlObject {
+    values := make([]objects.BlObject, args.Nchildren)
+    for i, arg := range args.Children {
+        if arg.NodeType == token.KWARG {
+            errpkg.SetErrmsg("%s() takes no keyword arguments",
+                             f.Name)
+            return nil
+        }
+        values[i] = e.exec(arg)
+    }
+    return e.bindLocals(f, values, self)
+}
+
+// Like buildLocals, for arguments that are evaluated.
+func (e *Eval) bindLocals(
+f *objects.BlFunctionObject,
+args []objects.BlObject,
+self *objects.BlInstanceObject,
 ) map[string]objects.BlObject {
     /*
      * argpos points to the position in the parameter
      * list where a stared parameter occurs.
      */
-    argpos := args.Nchildren
+    argpos := len(args)
     if self != nil {
         if f.StarParam && f.ParamLen == 0 {
         } else {
@@ -124,12 +175,12 @@ self *objects.BlInstanceObject,
     }
     var j int
     for ; i < argpos; i++ {
-        locals[f.Params[i]] = e.exec(args.Children[j])
+        locals[f.Params[i]] = args[j]
         j++
     }
     if f.StarParam {
-        for ; j < args.Nchildren; j++ {
-            list.Append(e.exec(args.Children[j]))
+        for ; j < len(args); j++ {
+            list.Append(args[j])
         }
         locals[f.Params[argpos]] = list
     }
@@ -187,7 +238,7 @@ func (e *Eval) exec(node *interm.Node) objects.BlObject {
             e.set(name, obj)
         case token.MAKE_FUNC:
             name := node.Children[0].Str
-            obj := e.makeFunc(name, node.Children[1],
+            obj := e.makeFunc(name, node.LineNum, node.Children[1],
                               node.Children[2])
             if obj == nil {
                 goto err
@@ -363,6 +414,22 @@ func (e *Eval) exec(node *interm.Node) objects.BlObject {
                 goto err
             }
             return ret
+        case token.FLOORDIV:
+            a := e.exec(node.Children[0])
+            b := e.exec(node.Children[1])
+            ret := blNumFloorDivide(a, b, "//")
+            if ret == nil {
+                goto err
+            }
+            return ret
+        case token.POWER:
+            a := e.exec(node.Children[0])
+            b := e.exec(node.Children[1])
+            ret := blNumPower(a, b, "**")
+            if ret == nil {
+                goto err
+            }
+            return ret
         /*
          * Begin comparison operators. All of them have
          * a non-terminal with type token.COMP_OP.
@@ -371,7 +438,22 @@ func (e *Eval) exec(node *interm.Node) objects.BlObject {
             o := node.Children[0]
             a := e.exec(o.Children[0])
             b := e.exec(o.Children[1])
-            res := blCmp(a, b, o.NodeType)
+            var res objects.BlObject
+            switch o.NodeType {
+                case token.IN, token.NOT_IN:
+                    ret := e.contains(b, a)
+                    if ret == -1 {
+                        goto err
+                    }
+                    res = objects.NewBlBool((ret == 1) ==
+                                            (o.NodeType == token.IN))
+                case token.IS:
+                    res = objects.NewBlBool(a == b)
+                case token.IS_NOT:
+                    res = objects.NewBlBool(a != b)
+                default:
+                    res = blCmp(a, b, o.NodeType)
+            }
             if res == nil {
                 goto err
             }
@@ -393,7 +475,7 @@ func (e *Eval) exec(node *interm.Node) objects.BlObject {
             obj := e.exec(node.Children[0])
             switch t := obj.(type) {
             case *objects.BlClassObject:
-                ret := e.newInstance(t, node.Children[1])
+                ret := e.newInstance(t, node)
                 if ret == nil {
                     goto err
                 }
@@ -448,12 +530,14 @@ func (e *Eval) exec(node *interm.Node) objects.BlObject {
                 if locals == nil {
                     goto err
                 }
+                e.frame.SetNode(node)
                 ret = e.callFunction(t, locals)
             case *objects.BlMethodObject:
                 locals := e.buildLocals(t.F, node.Children[1], t.Self)
                 if locals == nil {
                     goto err
                 }
+                e.frame.SetNode(node)
                 ret = e.callFunction(t.F, locals)
             default:
                 errpkg.SetErrmsg("'%s' object is not callable",
@@ -476,7 +560,7 @@ func (e *Eval) exec(node *interm.Node) objects.BlObject {
             robj, ok := key.(*objects.BlRangeObject)
             var ret objects.BlObject
             if ok {
-                ret = blGetSlice(obj, robj.S, robj.E)
+                ret = blGetSlice(obj, robj)
             } else {
                 ret = blGetItem(obj, key)
             }
@@ -490,6 +574,12 @@ func (e *Eval) exec(node *interm.Node) objects.BlObject {
                 list.Append(e.exec(elem))
             }
             return list
+        case token.TUPLE:
+            tuple := make([]objects.BlObject, node.Nchildren)
+            for i, elem := range node.Children {
+                tuple[i] = e.exec(elem)
+            }
+            return objects.NewBlTuple(tuple...)
         case token.HASH:
             m := objects.NewBlMap()
             for _, elem := range node.Children {
@@ -501,30 +591,51 @@ func (e *Eval) exec(node *interm.Node) objects.BlObject {
                 }
             }
             return m
-        case token.RANGE:
-            var s, end int = 0, 0x7fffffff
-            if (node.Flags & interm.FLAG_RANGELHS) != 0 {
-                v := e.exec(node.Children[0])
-                iobj, ok := v.(*objects.BlIntObject)
-                if !ok {
-                    goto out
+        case token.SET:
+            s := objects.NewBlSet()
+            for _, elem := range node.Children {
+                if s.Add(e.exec(elem)) == -1 {
+                    goto err
                 }
-                s = int(iobj.Value)
             }
-            if (node.Flags & interm.FLAG_RANGERHS) != 0 {
-                var v objects.BlObject
-                if node.Nchildren == 2 {
-                    v = e.exec(node.Children[1])
-                } else {
-                    v = e.exec(node.Children[0])
+            return s
+        case token.RANGE:
+            var robj *objects.BlRangeObject
+            // The ends and the step, in the order of the children.
+            bounds := [3]int{0, 0, 1}
+            flags := [3]int{interm.FLAG_RANGELHS, interm.FLAG_RANGERHS,
+                            interm.FLAG_RANGESTEP}
+            open := objects.RANGE_OPEN_S | objects.RANGE_OPEN_E
+            pos := 0
+            for i, flag := range flags {
+                if (node.Flags & flag) == 0 {
+                    continue
                 }
+                v := e.exec(node.Children[pos])
+                pos++
                 iobj, ok := v.(*objects.BlIntObject)
                 if !ok {
                     goto out
                 }
-                end = int(iobj.Value)
+                if iobj.IsBig() {
+                    errpkg.SetErrmsg("range bound out of range")
+                    goto err
+                }
+                bounds[i] = int(iobj.Value)
+            }
+            if (node.Flags & interm.FLAG_RANGELHS) != 0 {
+                open &^= objects.RANGE_OPEN_S
+            }
+            if (node.Flags & interm.FLAG_RANGERHS) != 0 {
+                open &^= objects.RANGE_OPEN_E
+            }
+            robj = objects.NewBlSteppedRange(bounds[0], bounds[1],
+                                             bounds[2],
+                                             node.Str == "..=", open)
+            if robj == nil {
+                goto err
             }
-            return objects.NewBlRange(s, end)
+            return robj
             out:
                 errpkg.SetErrmsg("range indices must be" +
                                  " integers")
@@ -535,12 +646,30 @@ func (e *Eval) exec(node *interm.Node) objects.BlObject {
                 goto err
             }
             return objects.NewBlString(*value)
+        case token.INTERP:
+            var buf strings.Builder
+            for _, part := range node.Children {
+                if part.NodeType == token.STRING {
+                    value := parseString(part.Str)
+                    if value == nil {
+                        goto err
+                    }
+                    buf.WriteString(*value)
+                    continue
+                }
+                sobj := objects.BlStr(e.exec(part))
+                if sobj == nil {
+                    goto err
+                }
+                buf.WriteString(sobj.Value)
+            }
+            return objects.NewBlString(buf.String())
         case token.INTEGER:
-            value := parseInt(node.Str)
-            if value == -1 {
+            iobj := parseInt(node.Str)
+            if iobj == nil {
                 goto err
             }
-            return objects.NewBlInt(value)
+            return iobj
         case token.FLOAT:
             value := parseFloat(node.Str)
             if value == -1.0 {
@@ -560,6 +689,8 @@ func (e *Eval) exec(node *interm.Node) objects.BlObject {
     }
     return nil
 err:
+    // Point the traceback at the node that failed.
+    e.frame.SetNode(node)
     e.tracefunc(e.frame)
     return nil
 }
@@ -616,7 +747,7 @@ extends, classblock *interm.Node) objects.BlObject {
 }
 
 func (e *Eval) makeFunc(
-name string,
+name string, lineNum int,
 paramsNode, block *interm.Node) objects.BlObject {
     var params []string
     for _, i := range paramsNode.Children {
@@ -626,9 +757,10 @@ paramsNode, block *interm.Node) objects.BlObject {
     if (paramsNode.Flags & interm.FLAG_STARPARAM) != 0 {
         starParam = true
     } 
-    return objects.NewBlFunction(e.pathname, name, e.frame.Globals,
-                                 params, paramsNode.Nchildren,
-                                 block, starParam) 
+    return objects.NewBlFunction(e.pathname, name, lineNum,
+                                 e.frame.Globals, params,
+                                 paramsNode.Nchildren, block,
+                                 starParam) 
 }
 
 func (e *Eval) ifStmt(node *interm.Node) {
@@ -749,7 +881,7 @@ func (e *Eval) assign(node *interm.Node) int {
             val := e.exec(node.Children[1])
             robj, ok := key.(*objects.BlRangeObject)
             if ok {
-                return blSetSlice(obj, val, robj.S, robj.E)
+                return blSetSlice(obj, val, robj)
             }
             return blSetItem(obj, val, key)
     }
@@ -816,15 +948,50 @@ func (e *Eval) augassign(node *interm.Node) int {
 func (e *Eval) callBuiltin(
 f *objects.BlGFunctionObject, args *interm.Node,
 rcv objects.BlObject, meth bool) objects.BlObject {
+    arglist := make([]objects.BlObject, 0, args.Nchildren)
+    var kwargs *objects.BlMapObject
+    for _, arg := range args.Children {
+        if arg.NodeType != token.KWARG {
+            arglist = append(arglist, e.exec(arg))
+            continue
+        }
+        if kwargs == nil {
+            kwargs = objects.NewBlMap()
+        }
+        blSetItem(kwargs, e.exec(arg.Children[0]),
+                  objects.NewBlString(arg.Str))
+    }
+    return e.applyBuiltin(f, arglist, kwargs, rcv, meth)
+}
+
+/*
+ * Like callBuiltin, for arguments that are evaluated.
+ * Builtins flagged GFUNC_KEYWORDS get the keyword
+ * arguments as a map after the others.
+ */
+func (e *Eval) applyBuiltin(
+f *objects.BlGFunctionObject, arglist []objects.BlObject,
+kwargs *objects.BlMapObject, rcv objects.BlObject,
+meth bool) objects.BlObject {
     if (f.Flags & objects.GFUNC_NOARGS) != 0 &&
-        args.Nchildren > 0 {
+        len(arglist) > 0 {
         errpkg.SetErrmsg("%s() takes no arguments",
                          f.Name)
         return nil
     }
-    arglist := make([]objects.BlObject, args.Nchildren)
-    for i, arg := range args.Children {
-        arglist[i] = e.exec(arg)
+    if (f.Flags & objects.GFUNC_KEYWORDS) != 0 {
+        if kwargs == nil {
+            kwargs = objects.NewBlMap()
+        }
+        arglist = append(arglist, kwargs)
+    } else if kwargs != nil {
+        errpkg.SetErrmsg("%s() takes no keyword arguments",
+                         f.Name)
+        return nil
+    }
+    if profiler != nil {
+        profiler.enter("builtin", f.Name, 0)
+        defer profiler.leave()
     }
     if !meth {
         return f.Function(nil, arglist...)
@@ -854,7 +1021,8 @@ locals map[string]objects.BlObject) (obj objects.BlObject) {
         }
     }()
     e.inFunction++
-    e.evalCode(f.Block, f.Globals, locals, f.Path, f.Name)
+    e.evalCode(f.Block, f.Globals, locals, f.Path, f.Name,
+               f.LineNum)
     e.inFunction--
     if e.inFunction == 0 {
         e.loopCount = 0
@@ -862,16 +1030,21 @@ locals map[string]objects.BlObject) (obj objects.BlObject) {
     return obj
 }
 
+/*
+ * 'node' is the MAKE_INSTANCE node, its second child
+ * holds the arguments for '__init__'.
+ */
 func (e *Eval) newInstance(class *objects.BlClassObject,
-                           args *interm.Node) objects.BlObject {
+                           node *interm.Node) objects.BlObject {
     iobj := objects.NewBlInstance(class)
     mobj := blGetMember(class, "__init__")
     if mobj != nil {
         mobj := mobj.(*objects.BlMethodObject)
-        locals := e.buildLocals(mobj.F, args, iobj)
+        locals := e.buildLocals(mobj.F, node.Children[1], iobj)
         if locals == nil {
             return nil
         }
+        e.frame.SetNode(node)
         ret := e.callFunction(mobj.F, locals)
         if ret == nil {
             return nil
@@ -880,6 +1053,81 @@ func (e *Eval) newInstance(class *objects.BlClassObject,
     return iobj
 }
 
+// Call a method of a Blue class with evaluated arguments.
+func (e *Eval) callMethod(m *objects.BlMethodObject,
+                          args ...objects.BlObject) objects.BlObject {
+    locals := e.bindLocals(m.F, args, m.Self)
+    if locals == nil {
+        return nil
+    }
+    return e.callFunction(m.F, locals)
+}
+
+/*
+ * Call a function, method or builtin with evaluated
+ * arguments, the way a CALL node would. Builtins that
+ * take callables reach this through objects.BlCall.
+ */
+func (e *Eval) call(obj objects.BlObject,
+                    args ...objects.BlObject) objects.BlObject {
+    switch t := obj.(type) {
+        case *objects.BlGFunctionObject:
+            return e.applyBuiltin(t, args, nil, nil, false)
+        case *objects.BlGMethodObject:
+            rcv := t.Self
+            if rcv == nil {
+                if len(args) > 0 {
+                    rcv, args = args[0], args[1:]
+                }
+                if rcv == nil || rcv.BlType() != t.Class {
+                    tobj := t.Class.(*objects.BlTypeObject)
+                    errpkg.SetErrmsg("method '%s' requires a '%s'" +
+                                     " object as receiver", t.F.Name,
+                                     tobj.Name)
+                    return nil
+                }
+            }
+            return e.applyBuiltin(t.F, args, nil, rcv, true)
+        case *objects.BlFunctionObject:
+            locals := e.bindLocals(t, args, nil)
+            if locals == nil {
+                return nil
+            }
+            return e.callFunction(t, locals)
+        case *objects.BlMethodObject:
+            return e.callMethod(t, args...)
+    }
+    errpkg.SetErrmsg("'%s' object is not callable",
+                     obj.BlType().Name)
+    return nil
+}
+
+/*
+ * Report whether 'container' holds 'item', 1 if it does,
+ * 0 if not and -1 on error. Instances answer through their
+ * '__contains__' method.
+ */
+func (e *Eval) contains(container,
+                        item objects.BlObject) int {
+    iobj, ok := container.(*objects.BlInstanceObject)
+    if !ok {
+        return blContains(container, item)
+    }
+    mobj, ok := blGetMember(iobj, "__contains__").(*objects.BlMethodObject)
+    if !ok {
+        errpkg.SetErrmsg("instance has no '__contains__' method")
+        return -1
+    }
+    ret := e.callMethod(mobj, item)
+    if ret == nil {
+        return -1
+    }
+    if blEvalCondition(ret) {
+        return 1
+    }
+    return 0
+}
+
 func (e *Eval) callType(obj *objects.BlTypeObject,
                         args *interm.Node) objects.BlObject {
     arglist := make([]objects.BlObject, args.Nchildren)
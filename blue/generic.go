@@ -4,6 +4,8 @@ package blue
 import (
     "fmt"
     "bytes"
+    "github.com/Magnus9/blue/token"
+    "github.com/Magnus9/blue/interm"
     "github.com/Magnus9/blue/errpkg"
     "github.com/Magnus9/blue/objects"
 )
@@ -11,12 +13,34 @@ import (
 func genericTraceFunc(frame *objects.BlFrame) {
     var buf bytes.Buffer
     for f := frame; f != nil; f = f.Prev {
-        str := fmt.Sprintf("in file <%s:%d>, func %s\n   %s\n",
-                           f.Pathname, f.Node.LineNum,
-                           f.Name, f.Node.Line)
+        str := fmt.Sprintf("in file <%s:%d>, func %s\n",
+                           f.Pathname, f.Node.LineNum, f.Name)
         buf.WriteString(str)
+        buf.WriteString(token.Caret("   ", f.Node.Line, f.Node.Col,
+                                    f.Node.EndCol))
+        buf.WriteByte('\n')
     }
     buf.WriteString(errpkg.Errmsg)
     
     panic(buf.String())
-}
\ No newline at end of file
+}
+/*
+ * Follows the frames for the profiler and coverage.
+ * Only hooked in while one of them is recording.
+ */
+func blTraceNode(frame *objects.BlFrame, node *interm.Node) {
+    if profiler != nil {
+        profiler.setLine(node.LineNum)
+    }
+    if coverage != nil {
+        coverage.hit(node)
+    }
+}
+
+func blSetTraceHook() {
+    if profiler != nil || coverage != nil {
+        objects.BlTraceNode = blTraceNode
+    } else {
+        objects.BlTraceNode = nil
+    }
+}
@@ -35,6 +35,20 @@ funcs []objects.BlGFunctionObject) *objects.BlModuleObject {
     return mod
 }
 
+/*
+ * Forget every module that was imported from a file, so
+ * the next import runs it again. The builtin modules
+ * stay as they are.
+ */
+func ResetModules() {
+    modules := GetModuleMap()
+    for name, mod := range modules {
+        if mod.Path != "builtin" {
+            delete(modules, name)
+        }
+    }
+}
+
 /*
  * The entry point of a module import. The chain
  * of routines is very simple because of how we do
@@ -100,7 +114,8 @@ func blLocateModule(name, path string) objects.BlObject {
             f, err = os.Open(fullpath + ".bl")
         }
         if f != nil {
-            return blLoadModule(f, name, fullpath)
+            // The module is known by the file it was read from.
+            return blLoadModule(f, name, f.Name())
         }
     }
     pstr := strings.Replace(path, string(filepath.Separator),
@@ -111,7 +126,11 @@ func blLocateModule(name, path string) objects.BlObject {
 
 func blLoadModule(fdesc *os.File,
                   name, fullpath string) objects.BlObject {
-    ast := parser.ParseFromFile(fullpath, fdesc)
+    ast, err := parser.ParseFromFile(fullpath, fdesc)
+    if err != nil {
+        errpkg.SetErrmsg("%s", err)
+        return nil
+    }
     mod := blAddModule(name, fullpath)
     return blExecModule(mod, ast)
 }
@@ -44,6 +44,10 @@ func blGetItem(obj, key objects.BlObject) objects.BlObject {
                              typeobj.Name)
             return nil
         }
+        if iobj.IsBig() {
+            errpkg.SetErrmsg("subscript position out of bounds")
+            return nil
+        }
         return blGetSeqItem(obj, int(iobj.Value))
     }
     errpkg.SetErrmsg("'%s' object is not subscriptable",
@@ -65,6 +69,10 @@ func blSetItem(obj, value, key objects.BlObject) int {
                              typeobj.Name)
             return -1
         }
+        if iobj.IsBig() {
+            errpkg.SetErrmsg("subscript position out of bounds")
+            return -1
+        }
         return blSetSeqItem(obj, value, int(iobj.Value))
     }
     errpkg.SetErrmsg("'%s' object does not support item" +
@@ -102,20 +110,17 @@ func blSetSeqItem(obj, value objects.BlObject, pos int) int {
     return -1
 }
 
-func blGetSlice(obj objects.BlObject, s, e int) objects.BlObject {
+/*
+ * Slices are taken with a range as the key, see
+ * BlRangeObject.Indices for how its ends are read.
+ */
+func blGetSlice(obj objects.BlObject,
+                robj *objects.BlRangeObject) objects.BlObject {
     typeobj := obj.BlType()
     if seq := typeobj.Sequence; seq != nil {
-        if seq.SqSlice != nil {
-            if s < 0 || e < 0 && seq.SqSize != nil {
-                siz := seq.SqSize(obj)
-                if s < 0 {
-                    s += siz
-                }
-                if e < 0 {
-                    e += siz
-                }
-            }
-            return seq.SqSlice(obj, s, e)
+        if seq.SqSlice != nil && seq.SqSize != nil {
+            s, e, step := robj.Indices(seq.SqSize(obj))
+            return seq.SqSlice(obj, s, e, step)
         }
     }
     errpkg.SetErrmsg("'%s' object is not slicable",
@@ -123,20 +128,13 @@ func blGetSlice(obj objects.BlObject, s, e int) objects.BlObject {
     return nil
 }
 
-func blSetSlice(obj, value objects.BlObject, s, e int) int {
+func blSetSlice(obj, value objects.BlObject,
+                robj *objects.BlRangeObject) int {
     typeobj := obj.BlType()
     if seq := typeobj.Sequence; seq != nil {
-        if seq.SqAssSlice != nil {
-            if s < 0 || e < 0 && seq.SqSize != nil {
-                siz := seq.SqSize(obj)
-                if s < 0 {
-                    s += siz
-                }
-                if e < 0 {
-                    e += siz
-                }
-            }
-            return seq.SqAssSlice(obj, value, s, e)
+        if seq.SqAssSlice != nil && seq.SqSize != nil {
+            s, e, step := robj.Indices(seq.SqSize(obj))
+            return seq.SqAssSlice(obj, value, s, e, step)
         }
     }
     errpkg.SetErrmsg("'%s' object does not support slice" +
@@ -341,6 +339,10 @@ err:
 }
 
 func blNumModulo(a, b objects.BlObject, op string) objects.BlObject {
+    // Strings format their operand, it is never coerced.
+    if _, ok := a.(*objects.BlStringObject); ok {
+        return a.BlType().Numbers.NumMod(a, b)
+    }
     if a.BlType().Numbers != nil {
         if objects.BlNumCoerce(&a, &b) == -1 {
             goto err
@@ -358,19 +360,61 @@ err:
     return nil
 }
 
+func blNumFloorDivide(a, b objects.BlObject,
+                      op string) objects.BlObject {
+    if a.BlType().Numbers != nil {
+        if objects.BlNumCoerce(&a, &b) == -1 {
+            goto err
+        }
+        typeobj := a.BlType()
+        if typeobj.Numbers != nil {
+            if fn := typeobj.Numbers.NumFloorDiv; fn != nil {
+                return fn(a, b)
+            }
+        }
+    }
+err:
+    errpkg.SetErrmsg("bad operand types for '%s'",
+                      op)
+    return nil
+}
+
+func blNumPower(a, b objects.BlObject, op string) objects.BlObject {
+    if a.BlType().Numbers != nil {
+        if objects.BlNumCoerce(&a, &b) == -1 {
+            goto err
+        }
+        typeobj := a.BlType()
+        if typeobj.Numbers != nil {
+            if fn := typeobj.Numbers.NumPow; fn != nil {
+                return fn(a, b)
+            }
+        }
+    }
+err:
+    errpkg.SetErrmsg("bad operand types for '%s'",
+                      op)
+    return nil
+}
+
 func blCmp(a, b objects.BlObject, op int) objects.BlObject {
     value := objects.BlCompare(a, b)
-    if op != token.EQ && value == -2 {
+    // Equality holds or not even for objects without order.
+    if op != token.EQ && op != token.NE && value == -2 {
         return nil
     }
     var res bool
-    switch op {
-        case token.EQ: res = value == 0
-        case token.NE: res = value != 0
-        case token.LT: res = value <  0
-        case token.LE: res = value <= 0
-        case token.GT: res = value >  0
-        case token.GE: res = value >= 0
+    if value == objects.CMP_UNORDERED {
+        res = op == token.NE
+    } else {
+        switch op {
+            case token.EQ: res = value == 0
+            case token.NE: res = value != 0
+            case token.LT: res = value <  0
+            case token.LE: res = value <= 0
+            case token.GT: res = value >  0
+            case token.GE: res = value >= 0
+        }
     }
     if res {
         return objects.BlTrue
@@ -378,6 +422,23 @@ func blCmp(a, b objects.BlObject, op int) objects.BlObject {
     return objects.BlFalse
 }
 
+/*
+ * Look 'item' up in a sequence or in the keys of a
+ * mapping.
+ */
+func blContains(container, item objects.BlObject) int {
+    typeobj := container.BlType()
+    if seq := typeobj.Sequence; seq != nil && seq.SqContains != nil {
+        return seq.SqContains(container, item)
+    }
+    if mp := typeobj.Mapping; mp != nil && mp.MpContains != nil {
+        return mp.MpContains(container, item)
+    }
+    errpkg.SetErrmsg("'%s' object is not a container",
+                     typeobj.Name)
+    return -1
+}
+
 /*
  * Objects that dont have an EvalCond function
  * returns true as default.
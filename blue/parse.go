@@ -9,49 +9,12 @@ import (
     "bytes"
     "strconv"
     "github.com/Magnus9/blue/errpkg"
+    "github.com/Magnus9/blue/objects"
 )
 
-func parseInt(value string) int64 {
-    size := len(value)
-    if size >= 2 {
-        if value[1] == 'X' || value[1] == 'x' {
-            return parseHex(value[2:], size - 2)
-        }
-    }
-    var prevValue, sum int64 = -1, 0
-    for i := 0; i < size && (sum >= prevValue); i++ {
-        prevValue = sum
-        sum = sum * 10 + int64(value[i]) - 48
-    }
-    if sum >= prevValue {
-        return sum
-    } else {
-        errpkg.SetErrmsg("number overflow")
-        return -1
-    }
-}
-
-func parseHex(value string, size int) int64 {
-    var prevValue, sum int64 = -1, 0
-    for i := 0; i < size && (sum >= prevValue); i++ {
-        ch := value[i]
-        switch {
-            case ch >= '0' && ch <= '9':
-                ch = ch - '0'
-            case ch >= 'A' && ch <= 'F':
-                ch = ch - 'A' + 10
-            case ch >= 'a' && ch <= 'f':
-                ch = ch - 'a' + 10
-        }
-        prevValue = sum
-        sum = sum * 16 + int64(ch)
-    }
-    if sum >= prevValue {
-        return sum
-    } else {
-        errpkg.SetErrmsg("number overflow")
-        return -1
-    }
+// Int literals are decimal or hex, and of any size.
+func parseInt(value string) *objects.BlIntObject {
+    return objects.BlIntFromString(value)
 }
 
 func parseFloat(value string) float64 {
@@ -82,6 +45,7 @@ func parseString(value string) *string {
                 case 'n':  buf.WriteByte('\n')
                 case 't':  buf.WriteByte('\t')
                 case 'r':  buf.WriteByte('\r')
+                case '#':  buf.WriteByte('#')
                 case 'X':
                     fallthrough
                 case 'x':
@@ -0,0 +1,334 @@
+package blue
+
+import (
+    "io"
+    "os"
+    "fmt"
+    "sort"
+    "time"
+    "strings"
+    "text/tabwriter"
+    "github.com/google/pprof/profile"
+)
+
+/*
+ * The profiler measures wall time between events: a
+ * frame is entered or left, or a frame moves on to a
+ * new line. The time since the last event is charged to
+ * the stack of functions as it was, which gives self
+ * time to the function and line on top, and cumulative
+ * time to every function on the stack.
+ */
+type profFunc struct {
+    name     string
+    pathname string
+    lineNum  int
+    calls    int
+    self     time.Duration
+    cum      time.Duration
+    // Number of activations on the stack, for recursion.
+    active   int
+}
+
+type profLine struct {
+    pathname string
+    lineNum  int
+    // Times execution moved on to the line.
+    hits     int
+    self     time.Duration
+}
+
+type profFrame struct {
+    fn      *profFunc
+    lineNum int
+    start   time.Time
+}
+
+type profSample struct {
+    stack []profFrame
+    calls int
+    time  time.Duration
+}
+
+type Profiler struct {
+    funcs   map[string]*profFunc
+    lines   map[string]*profLine
+    samples map[string]*profSample
+    stack   []profFrame
+    last    time.Time
+    start   time.Time
+}
+
+// The active profiler, nil when not profiling.
+var profiler *Profiler
+
+/*
+ * Start recording every frame that is run from now on.
+ * Only one profile can be recorded at a time.
+ */
+func StartProfile() *Profiler {
+    now := time.Now()
+    profiler = &Profiler{
+        funcs  : make(map[string]*profFunc),
+        lines  : make(map[string]*profLine),
+        samples: make(map[string]*profSample),
+        last   : now,
+        start  : now,
+    }
+    blSetTraceHook()
+    return profiler
+}
+
+/*
+ * Stop recording. Frames that are still active, because
+ * the program died with an error, are closed first.
+ */
+func (p *Profiler) Stop() {
+    for len(p.stack) > 0 {
+        p.leave()
+    }
+    profiler = nil
+    blSetTraceHook()
+}
+
+func profKey(pathname, name string, lineNum int) string {
+    return fmt.Sprintf("%s\x00%s\x00%d", pathname, name, lineNum)
+}
+
+/*
+ * Charge the time since the last event to the stack as
+ * it is now.
+ */
+func (p *Profiler) charge() {
+    now := time.Now()
+    elapsed := now.Sub(p.last)
+    p.last = now
+    if len(p.stack) == 0 {
+        return
+    }
+    top := p.stack[len(p.stack) - 1]
+    top.fn.self += elapsed
+    if l := p.line(top.fn.pathname, top.lineNum); l != nil {
+        l.self += elapsed
+    }
+    p.sample().time += elapsed
+}
+
+func (p *Profiler) line(pathname string, lineNum int) *profLine {
+    if lineNum == 0 {
+        return nil
+    }
+    key := profKey(pathname, "", lineNum)
+    l, ok := p.lines[key]
+    if !ok {
+        l = &profLine{pathname: pathname, lineNum: lineNum}
+        p.lines[key] = l
+    }
+    return l
+}
+
+// The sample for the stack as it is now.
+func (p *Profiler) sample() *profSample {
+    var buf strings.Builder
+    for _, f := range p.stack {
+        fmt.Fprintf(&buf, "%p:%d;", f.fn, f.lineNum)
+    }
+    key := buf.String()
+    s, ok := p.samples[key]
+    if !ok {
+        s = &profSample{
+            stack: append([]profFrame{}, p.stack...),
+        }
+        p.samples[key] = s
+    }
+    return s
+}
+
+func (p *Profiler) enter(pathname, name string, lineNum int) {
+    p.charge()
+    key := profKey(pathname, name, lineNum)
+    fn, ok := p.funcs[key]
+    if !ok {
+        fn = &profFunc{
+            name    : name,
+            pathname: pathname,
+            lineNum : lineNum,
+        }
+        p.funcs[key] = fn
+    }
+    fn.calls++
+    fn.active++
+    // The first node of the frame counts as a hit.
+    p.stack = append(p.stack, profFrame{
+        fn   : fn,
+        start: p.last,
+    })
+    p.sample().calls++
+}
+
+func (p *Profiler) leave() {
+    p.charge()
+    top := p.stack[len(p.stack) - 1]
+    p.stack = p.stack[:len(p.stack) - 1]
+    top.fn.active--
+    // Recursive calls are already in the outermost one.
+    if top.fn.active == 0 {
+        top.fn.cum += p.last.Sub(top.start)
+    }
+}
+
+func (p *Profiler) setLine(lineNum int) {
+    if len(p.stack) == 0 {
+        return
+    }
+    top := &p.stack[len(p.stack) - 1]
+    if top.lineNum == lineNum {
+        return
+    }
+    p.charge()
+    top.lineNum = lineNum
+    if l := p.line(top.fn.pathname, lineNum); l != nil {
+        l.hits++
+    }
+}
+
+/*
+ * Write the profile in the format of 'go tool pprof'.
+ * The sample values are the number of calls and the
+ * time in nanoseconds.
+ */
+func (p *Profiler) WritePprof(w io.Writer) error {
+    prof := &profile.Profile{
+        SampleType: []*profile.ValueType{
+            {Type: "calls", Unit: "count"},
+            {Type: "time", Unit: "nanoseconds"},
+        },
+        PeriodType   : &profile.ValueType{Type: "time",
+                                           Unit: "nanoseconds"},
+        Period       : 1,
+        TimeNanos    : p.start.UnixNano(),
+        DurationNanos: int64(p.last.Sub(p.start)),
+    }
+    functions := make(map[*profFunc]*profile.Function)
+    locations := make(map[string]*profile.Location)
+    for _, s := range p.samples {
+        sample := &profile.Sample{
+            Value: []int64{int64(s.calls), int64(s.time)},
+        }
+        // pprof wants the leaf first.
+        for i := len(s.stack) - 1; i >= 0; i-- {
+            f := s.stack[i]
+            fn, ok := functions[f.fn]
+            if !ok {
+                // pprof hides names in angle brackets, i.e "<main>".
+                fn = &profile.Function{
+                    ID        : uint64(len(functions) + 1),
+                    Name      : strings.Trim(f.fn.name, "<>"),
+                    SystemName: f.fn.name,
+                    Filename  : f.fn.pathname,
+                    StartLine : int64(f.fn.lineNum),
+                }
+                functions[f.fn] = fn
+                prof.Function = append(prof.Function, fn)
+            }
+            key := fmt.Sprintf("%p:%d", f.fn, f.lineNum)
+            loc, ok := locations[key]
+            if !ok {
+                loc = &profile.Location{
+                    ID  : uint64(len(locations) + 1),
+                    Line: []profile.Line{{
+                        Function: fn,
+                        Line    : int64(f.lineNum),
+                    }},
+                }
+                locations[key] = loc
+                prof.Location = append(prof.Location, loc)
+            }
+            sample.Location = append(sample.Location, loc)
+        }
+        prof.Sample = append(prof.Sample, sample)
+    }
+    return prof.Write(w)
+}
+
+func percent(d, total time.Duration) float64 {
+    if total == 0 {
+        return 0
+    }
+    return 100 * float64(d) / float64(total)
+}
+
+/*
+ * Print the functions sorted by self time, followed by
+ * the lines sorted the same way.
+ */
+func (p *Profiler) WriteTable(w io.Writer) {
+    total := p.last.Sub(p.start)
+    funcs := make([]*profFunc, 0, len(p.funcs))
+    for _, fn := range p.funcs {
+        funcs = append(funcs, fn)
+    }
+    sort.Slice(funcs, func(i, j int) bool {
+        if funcs[i].self != funcs[j].self {
+            return funcs[i].self > funcs[j].self
+        }
+        return funcs[i].name < funcs[j].name
+    })
+    tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+    fmt.Fprintf(tw, "calls\tself\tself%%\tcum\tcum%%\t\tfunction\n")
+    for _, fn := range funcs {
+        where := fn.pathname
+        if fn.lineNum > 0 {
+            where = fmt.Sprintf("%s:%d", fn.pathname, fn.lineNum)
+        }
+        fmt.Fprintf(tw, "%d\t%v\t%.2f%%\t%v\t%.2f%%\t\t%s (%s)\n",
+                    fn.calls, fn.self, percent(fn.self, total),
+                    fn.cum, percent(fn.cum, total), fn.name, where)
+    }
+    tw.Flush()
+
+    lines := make([]*profLine, 0, len(p.lines))
+    for _, l := range p.lines {
+        lines = append(lines, l)
+    }
+    sort.Slice(lines, func(i, j int) bool {
+        if lines[i].self != lines[j].self {
+            return lines[i].self > lines[j].self
+        }
+        if lines[i].pathname != lines[j].pathname {
+            return lines[i].pathname < lines[j].pathname
+        }
+        return lines[i].lineNum < lines[j].lineNum
+    })
+    fmt.Fprintln(w)
+    tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+    fmt.Fprintf(tw, "hits\tself\tself%%\t\tline\n")
+    for _, l := range lines {
+        fmt.Fprintf(tw, "%d\t%v\t%.2f%%\t\t%s:%d\n", l.hits, l.self,
+                    percent(l.self, total), l.pathname, l.lineNum)
+    }
+    tw.Flush()
+}
+
+/*
+ * Write the profile to 'pathname'. Files ending in
+ * '.txt' and "-", for stdout, get the flat table, the
+ * rest get the pprof format.
+ */
+func (p *Profiler) WriteFile(pathname string) error {
+    if pathname == "-" {
+        p.WriteTable(os.Stdout)
+        return nil
+    }
+    f, err := os.Create(pathname)
+    if err != nil {
+        return err
+    }
+    if strings.HasSuffix(pathname, ".txt") {
+        p.WriteTable(f)
+    } else if err = p.WritePprof(f); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+    "os"
+    "fmt"
+    "flag"
+    "io/ioutil"
+    "github.com/Magnus9/blue/checker"
+)
+
+/*
+ * blue check file ...
+ *
+ * Prints a file:line:col diagnostic for every problem
+ * found, quoting the line it is on, and exits with
+ * status 1 if there were any.
+ */
+func checkMain(args []string) int {
+    flags := flag.NewFlagSet("check", flag.ContinueOnError)
+    flags.Usage = func() {
+        fmt.Fprintf(os.Stderr, "usage: blue check file ...\n")
+    }
+    if flags.Parse(args) != nil {
+        return 2
+    }
+    if flags.NArg() == 0 {
+        flags.Usage()
+        return 2
+    }
+    c := checker.New()
+    status := 0
+    for _, pathname := range flags.Args() {
+        src, err := ioutil.ReadFile(pathname)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%s\n", err)
+            status = 1
+            continue
+        }
+        diags, err := c.Check(pathname, src)
+        if err != nil {
+            fmt.Println(err)
+            status = 1
+            continue
+        }
+        for _, d := range diags {
+            fmt.Println(d)
+            fmt.Println(d.Caret())
+        }
+        if len(diags) > 0 {
+            status = 1
+        }
+    }
+    return status
+}
@@ -0,0 +1,351 @@
+/*
+ * Package checker finds mistakes in blue programs
+ * without running them. It walks the tree made by the
+ * parser, resolves every name the same way the evaluator
+ * would (locals, globals, builtins) and reports the
+ * problems the evaluator would only find at runtime.
+ */
+package checker
+
+import (
+    "os"
+    "fmt"
+    "sort"
+    "io/ioutil"
+    "path/filepath"
+    "github.com/Magnus9/blue/blue"
+    "github.com/Magnus9/blue/token"
+    "github.com/Magnus9/blue/interm"
+    "github.com/Magnus9/blue/parser"
+    "github.com/Magnus9/blue/objects"
+)
+const (
+    SYM_VAR = iota
+    SYM_PARAM
+    SYM_FUNC
+    SYM_CLASS
+    SYM_MODULE
+    SYM_BUILTIN
+)
+
+/*
+ * A problem found in a file. Col and EndCol mark the
+ * expression it is about on the line, as on the nodes.
+ */
+type Diagnostic struct {
+    Pathname string
+    LineNum  int
+    Col      int
+    EndCol   int
+    Message  string
+    // The source line, for quoting it.
+    Line     string
+}
+
+func (d *Diagnostic) String() string {
+    return fmt.Sprintf("%s:%d:%d: %s", d.Pathname, d.LineNum,
+                       d.Col, d.Message)
+}
+
+// The line the diagnostic is on with the expression marked.
+func (d *Diagnostic) Caret() string {
+    return token.Caret("   ", d.Line, d.Col, d.EndCol)
+}
+
+/*
+ * A name bound in one of the scopes. Node is the node
+ * that defines it: MAKE_FUNC, MAKE_CLASS, the PATH of
+ * an import or the NAME that is assigned to.
+ */
+type Symbol struct {
+    Name     string
+    Kind     int
+    Pathname string
+    Node     *interm.Node
+    // The value first assigned to a SYM_VAR, if any.
+    Value    *interm.Node
+    // The imported module for SYM_MODULE.
+    Module   *Module
+    // The runtime object for SYM_BUILTIN.
+    Object   objects.BlObject
+    used     bool
+}
+
+type Module struct {
+    Name     string
+    Pathname string
+    Root     *interm.Node
+    Globals  map[string]*Symbol
+}
+
+type Checker struct {
+    builtins map[string]*Symbol
+    // Loaded modules by the path used to import them.
+    modules  map[string]*Module
+    // Modules that failed to load and why.
+    failed   map[string]error
+}
+
+func New() *Checker {
+    modules := blue.GetModuleMap()
+    if _, ok := modules["builtins"]; !ok {
+        blue.Init(nil)
+    }
+    c := &Checker{
+        builtins: builtinSymbols(modules["builtins"]),
+        modules : make(map[string]*Module),
+        failed  : make(map[string]error),
+    }
+    return c
+}
+
+func builtinSymbols(mod *objects.BlModuleObject) map[string]*Symbol {
+    m := make(map[string]*Symbol, len(mod.Locals))
+    for name, obj := range mod.Locals {
+        m[name] = &Symbol{
+            Name    : name,
+            Kind    : SYM_BUILTIN,
+            Pathname: "builtin",
+            Object  : obj,
+        }
+    }
+    return m
+}
+
+/*
+ * Parse a program and run it through the checks.
+ * Parse errors are returned as the error, everything
+ * else is returned as diagnostics sorted by line.
+ */
+func (c *Checker) Check(pathname string,
+                        src []byte) ([]*Diagnostic, error) {
+    root, err := Parse(pathname, src)
+    if err != nil {
+        return nil, err
+    }
+    return c.CheckTree(pathname, root), nil
+}
+
+func (c *Checker) CheckTree(pathname string,
+                            root *interm.Node) []*Diagnostic {
+    f := &fileChecker{
+        c       : c,
+        pathname: pathname,
+        globals : c.collectGlobals(pathname, root),
+    }
+    f.visit(root)
+    sort.SliceStable(f.diags, func(i, j int) bool {
+        a, b := f.diags[i], f.diags[j]
+        if a.LineNum != b.LineNum {
+            return a.LineNum < b.LineNum
+        }
+        if a.Col != b.Col {
+            return a.Col < b.Col
+        }
+        return a.Message < b.Message
+    })
+    return f.diags
+}
+
+/*
+ * Parse a program. On syntax errors the error is a
+ * parser.ErrorList and the tree holds what could be
+ * parsed around them.
+ */
+func Parse(pathname string, src []byte) (*interm.Node, error) {
+    root, _, err := parser.ParseWithComments(pathname, string(src))
+    return root, err
+}
+
+/*
+ * Collect the names a file binds at the top level. This
+ * is flow insensitive, functions can refer to globals
+ * that are assigned further down.
+ */
+func (c *Checker) collectGlobals(pathname string,
+                                 root *interm.Node) map[string]*Symbol {
+    m := make(map[string]*Symbol)
+    var walk func(node *interm.Node, inFunction bool)
+    walk = func(node *interm.Node, inFunction bool) {
+        switch node.NodeType {
+            case token.MAKE_FUNC:
+                if !inFunction {
+                    define(m, node.Children[0].Str, SYM_FUNC,
+                           pathname, node)
+                }
+                // Imports always bind in the globals.
+                walk(node.Children[2], true)
+            case token.MAKE_CLASS:
+                define(m, node.Children[0].Str, SYM_CLASS,
+                       pathname, node)
+                for _, n := range node.Children[2].Children {
+                    if n.NodeType == token.MAKE_FUNC {
+                        walk(n.Children[2], true)
+                    }
+                }
+            case token.IMPORT:
+                for _, path := range node.Children {
+                    name, mod, _ := c.importModule(path)
+                    sym := define(m, name, SYM_MODULE, pathname,
+                                  path)
+                    if sym.Module == nil {
+                        sym.Module = mod
+                    }
+                }
+            default:
+                if !inFunction {
+                    collectAssigns(m, pathname, node)
+                }
+                if node.NodeType == token.FILE_INPUT || isBlock(node) {
+                    for _, n := range node.Children {
+                        walk(n, inFunction)
+                    }
+                }
+        }
+    }
+    walk(root, false)
+    return m
+}
+
+func isBlock(node *interm.Node) bool {
+    switch node.NodeType {
+        case token.BLOCK, token.IF, token.WHILE, token.FOR:
+            return true
+    }
+    return false
+}
+
+/*
+ * Bind the names a single node assigns to. Blocks are
+ * left to the caller.
+ */
+func collectAssigns(m map[string]*Symbol, pathname string,
+                    node *interm.Node) {
+    switch node.NodeType {
+        case token.ASSIGN:
+            if lhs := node.Children[0]; lhs.NodeType == token.NAME {
+                sym := define(m, lhs.Str, SYM_VAR, pathname, lhs)
+                if sym.Value == nil {
+                    sym.Value = node.Children[1]
+                }
+            }
+        case token.FOR:
+            define(m, node.Children[0].Str, SYM_VAR, pathname,
+                   node.Children[0])
+        case token.WHILE:
+            // Assignments after the condition run every iteration.
+            for _, n := range node.Children[1:node.Nchildren - 1] {
+                collectAssigns(m, pathname, n)
+            }
+    }
+}
+
+func define(m map[string]*Symbol, name string, kind int,
+            pathname string, node *interm.Node) *Symbol {
+    sym, ok := m[name]
+    if ok {
+        /*
+         * Functions and classes win over plain variables
+         * so calls can be checked against the definition.
+         */
+        if sym.Kind == SYM_VAR && kind != SYM_VAR {
+            sym.Kind, sym.Node = kind, node
+        }
+        return sym
+    }
+    sym = &Symbol{
+        Name    : name,
+        Kind    : kind,
+        Pathname: pathname,
+        Node    : node,
+    }
+    m[name] = sym
+    return sym
+}
+
+/*
+ * Resolve an import the same way blImportModule and
+ * blLocateModule do: builtin modules first, then every
+ * directory in system.path.
+ */
+func (c *Checker) importModule(path *interm.Node) (string, *Module,
+                                                   error) {
+    var names []string
+    for _, n := range path.Children {
+        names = append(names, n.Str)
+    }
+    rel := filepath.Join(names...)
+    name := filepath.Base(rel)
+    if mod, ok := c.modules[rel]; ok {
+        return name, mod, nil
+    }
+    if err, ok := c.failed[rel]; ok {
+        return name, nil, err
+    }
+    mod, err := c.loadModule(name, rel)
+    if err != nil {
+        c.failed[rel] = err
+        return name, nil, err
+    }
+    return name, mod, nil
+}
+
+func (c *Checker) loadModule(name, rel string) (*Module, error) {
+    modules := blue.GetModuleMap()
+    if bmod, ok := modules[rel]; ok && bmod.Path == "builtin" {
+        mod := &Module{
+            Name    : name,
+            Pathname: bmod.Path,
+            Globals : builtinSymbols(bmod),
+        }
+        c.modules[rel] = mod
+        return mod, nil
+    }
+    for _, dir := range searchPath() {
+        fullpath := filepath.Join(dir, rel)
+        var pathname string
+        if stat, err := os.Stat(fullpath); err == nil && stat.IsDir() {
+            pathname = filepath.Join(fullpath, name + ".bl")
+        } else {
+            pathname = fullpath + ".bl"
+        }
+        src, err := ioutil.ReadFile(pathname)
+        if err != nil {
+            continue
+        }
+        mod := &Module{
+            Name    : name,
+            Pathname: pathname,
+        }
+        // Insert before collecting so import cycles end here.
+        c.modules[rel] = mod
+        root, err := Parse(pathname, src)
+        if err != nil {
+            delete(c.modules, rel)
+            return nil, err
+        }
+        mod.Root    = root
+        mod.Globals = c.collectGlobals(pathname, root)
+        return mod, nil
+    }
+    return nil, fmt.Errorf("failed to load module '%s'",
+                           filepath.ToSlash(rel))
+}
+
+func searchPath() []string {
+    var paths []string
+    system, ok := blue.GetModuleMap()["system"]
+    if !ok {
+        return paths
+    }
+    lobj, ok := system.Locals["path"].(*objects.BlListObject)
+    if !ok {
+        return paths
+    }
+    for _, obj := range lobj.GetList() {
+        if sobj, ok := obj.(*objects.BlStringObject); ok {
+            paths = append(paths, sobj.Value)
+        }
+    }
+    return paths
+}
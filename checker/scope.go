@@ -0,0 +1,130 @@
+package checker
+
+import (
+    "sort"
+    "github.com/Magnus9/blue/token"
+    "github.com/Magnus9/blue/interm"
+)
+
+/*
+ * The names visible from one line of a file, used by
+ * tooling that asks questions about a position instead
+ * of checking the whole file.
+ */
+type Scope struct {
+    Locals   map[string]*Symbol
+    Members  map[string]*Symbol
+    Globals  map[string]*Symbol
+    Builtins map[string]*Symbol
+    // The MAKE_FUNC enclosing the line, nil at top level.
+    Function *interm.Node
+}
+
+func (c *Checker) ScopeAt(pathname string, root *interm.Node,
+                          lineNum int) *Scope {
+    scope := &Scope{
+        Globals : c.collectGlobals(pathname, root),
+        Builtins: c.builtins,
+    }
+    for _, n := range root.Children {
+        if !encloses(n, lineNum) {
+            continue
+        }
+        switch n.NodeType {
+            case token.MAKE_FUNC:
+                scope.enterFunction(pathname, n)
+            case token.MAKE_CLASS:
+                classblock := n.Children[2]
+                for _, m := range classblock.Children {
+                    if m.NodeType == token.MAKE_FUNC &&
+                       encloses(m, lineNum) {
+                        scope.enterFunction(pathname, m)
+                        return scope
+                    }
+                }
+                scope.Members = make(map[string]*Symbol)
+                for _, m := range classblock.Children {
+                    if m.NodeType == token.MAKE_FUNC {
+                        define(scope.Members, m.Children[0].Str,
+                               SYM_FUNC, pathname, m)
+                    } else {
+                        collectLocals(scope.Members, pathname, m)
+                    }
+                }
+        }
+    }
+    return scope
+}
+
+func encloses(node *interm.Node, lineNum int) bool {
+    end := node.EndLineNum
+    if node.NodeType == token.MAKE_CLASS {
+        end = node.Children[2].EndLineNum
+    }
+    return node.LineNum <= lineNum && lineNum <= end
+}
+
+func (s *Scope) enterFunction(pathname string, node *interm.Node) {
+    s.Function = node
+    s.Locals = make(map[string]*Symbol)
+    for _, param := range node.Children[1].Children {
+        define(s.Locals, param.Str, SYM_PARAM, pathname, param)
+    }
+    collectLocals(s.Locals, pathname, node.Children[2])
+}
+
+// Same order as Eval.get.
+func (s *Scope) Lookup(name string) *Symbol {
+    var sym *Symbol
+    if s.Members != nil {
+        sym = s.Members[name]
+    } else if s.Locals != nil {
+        sym = s.Locals[name]
+    }
+    if sym == nil {
+        sym = s.Globals[name]
+        if sym == nil {
+            sym = s.Builtins[name]
+        }
+    }
+    return sym
+}
+
+// Every visible symbol, inner scopes shadowing outer ones.
+func (s *Scope) Symbols() []*Symbol {
+    seen := make(map[string]struct{})
+    var syms []*Symbol
+    for _, m := range []map[string]*Symbol{s.Members, s.Locals,
+                                           s.Globals, s.Builtins} {
+        for name, sym := range m {
+            if _, ok := seen[name]; !ok {
+                seen[name] = struct{}{}
+                syms = append(syms, sym)
+            }
+        }
+    }
+    sort.Slice(syms, func(i, j int) bool {
+        return syms[i].Name < syms[j].Name
+    })
+    return syms
+}
+
+/*
+ * Signature of a function symbol the way it was written,
+ * i.e "def name(a, *b)".
+ */
+func Signature(node *interm.Node) string {
+    params := node.Children[1]
+    str := "def " + node.Children[0].Str + "("
+    for i, p := range params.Children {
+        if i > 0 {
+            str += ", "
+        }
+        if i == params.Nchildren - 1 &&
+           (params.Flags & interm.FLAG_STARPARAM) != 0 {
+            str += "*"
+        }
+        str += p.Str
+    }
+    return str + ")"
+}
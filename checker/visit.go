@@ -0,0 +1,347 @@
+package checker
+
+import (
+    "fmt"
+    "strings"
+    "github.com/Magnus9/blue/token"
+    "github.com/Magnus9/blue/interm"
+    "github.com/Magnus9/blue/objects"
+)
+
+/*
+ * The state of the walk over a single file. locals is
+ * nil outside of functions and members is nil outside
+ * of class bodies, mirroring Eval.get.
+ */
+type fileChecker struct {
+    c          *Checker
+    pathname   string
+    diags      []*Diagnostic
+    globals    map[string]*Symbol
+    locals     map[string]*Symbol
+    members    map[string]*Symbol
+    // Set if the class being checked extends another one.
+    inherits   bool
+    inFunction bool
+    loopCount  int
+}
+
+func (f *fileChecker) report(node *interm.Node, format string,
+                             values ...interface{}) {
+    f.diags = append(f.diags, &Diagnostic{
+        Pathname: f.pathname,
+        LineNum : node.LineNum,
+        Col     : node.Col,
+        EndCol  : node.EndCol,
+        Message : fmt.Sprintf(format, values...),
+        Line    : node.Line,
+    })
+}
+
+func (f *fileChecker) lookup(name string) *Symbol {
+    var sym *Symbol
+    if f.members != nil {
+        sym = f.members[name]
+    } else if f.locals != nil {
+        sym = f.locals[name]
+    }
+    if sym == nil {
+        sym = f.globals[name]
+        if sym == nil {
+            sym = f.c.builtins[name]
+        }
+    }
+    return sym
+}
+
+func (f *fileChecker) read(node *interm.Node) *Symbol {
+    sym := f.lookup(node.Str)
+    if sym == nil {
+        // The name might be inherited from the base class.
+        if f.members == nil || !f.inherits {
+            f.report(node, "undefined name '%s'", node.Str)
+        }
+        return nil
+    }
+    sym.used = true
+    return sym
+}
+
+func (f *fileChecker) visitChildren(node *interm.Node) {
+    for _, n := range node.Children {
+        f.visit(n)
+    }
+}
+
+func (f *fileChecker) visit(node *interm.Node) {
+    switch node.NodeType {
+        case token.MAKE_FUNC:
+            f.function(node)
+        case token.MAKE_CLASS:
+            f.class(node)
+        case token.IMPORT:
+            for _, path := range node.Children {
+                if _, _, err := f.c.importModule(path); err != nil {
+                    f.report(path, "%s", err)
+                }
+            }
+        case token.IF:
+            for _, n := range node.Children {
+                if n.NodeType != token.ELIF {
+                    f.visit(n)
+                }
+            }
+        case token.WHILE:
+            f.visit(node.Children[0])
+            f.loopCount++
+            for _, n := range node.Children[1:] {
+                f.visit(n)
+            }
+            f.loopCount--
+        case token.FOR:
+            f.visit(node.Children[1])
+            f.loopCount++
+            f.visit(node.Children[2])
+            f.loopCount--
+        case token.RETURN:
+            if !f.inFunction {
+                f.report(node, "return outside function")
+            }
+            f.visitChildren(node)
+        case token.BREAK, token.CONTINUE:
+            if f.loopCount == 0 {
+                f.report(node, "%s outside loop", node.Str)
+            }
+        case token.ASSIGN:
+            f.target(node.Children[0])
+            f.visit(node.Children[1])
+        case token.AUGASSIGN:
+            op := node.Children[0]
+            if lhs := op.Children[0]; lhs.NodeType == token.NAME {
+                // Reading for the operator does not count as a use.
+                if f.lookup(lhs.Str) == nil {
+                    f.report(lhs, "undefined name '%s'", lhs.Str)
+                }
+            } else {
+                f.target(lhs)
+            }
+            f.visit(op.Children[1])
+        case token.NAME:
+            f.read(node)
+        case token.MEMBER:
+            f.visit(node.Children[0])
+            f.member(node)
+        case token.CALL:
+            f.visitChildren(node)
+            f.call(node, f.callee(node.Children[0]),
+                   node.Children[1])
+        case token.MAKE_INSTANCE:
+            f.visit(node.Children[1])
+            sym := f.read(node.Children[0])
+            if sym != nil && sym.Kind == SYM_CLASS {
+                f.construct(node, sym)
+            }
+        default:
+            f.visitChildren(node)
+    }
+}
+
+/*
+ * The left hand side of an assignment. Names are bound,
+ * not read, so only members and subscripts are visited.
+ */
+func (f *fileChecker) target(node *interm.Node) {
+    switch node.NodeType {
+        case token.NAME:
+        case token.MEMBER:
+            f.visit(node.Children[0])
+        default:
+            f.visitChildren(node)
+    }
+}
+
+func (f *fileChecker) function(node *interm.Node) {
+    saved := *f
+    f.locals = make(map[string]*Symbol)
+    f.members = nil
+    f.inFunction = true
+    f.loopCount = 0
+
+    for _, param := range node.Children[1].Children {
+        define(f.locals, param.Str, SYM_PARAM, f.pathname, param)
+    }
+    collectLocals(f.locals, f.pathname, node.Children[2])
+    f.visit(node.Children[2])
+
+    for _, sym := range f.locals {
+        if sym.Kind == SYM_VAR && !sym.used &&
+           !strings.HasPrefix(sym.Name, "_") {
+            f.report(sym.Node, "local variable '%s' assigned but" +
+                     " never used", sym.Name)
+        }
+    }
+    diags := f.diags
+    *f = saved
+    f.diags = diags
+}
+
+func collectLocals(m map[string]*Symbol, pathname string,
+                   node *interm.Node) {
+    collectAssigns(m, pathname, node)
+    if isBlock(node) {
+        for _, n := range node.Children {
+            collectLocals(m, pathname, n)
+        }
+    }
+}
+
+func (f *fileChecker) class(node *interm.Node) {
+    extends := node.Children[1]
+    if extends.Nchildren > 0 {
+        f.read(extends.Children[0])
+    }
+    saved := *f
+    f.members = make(map[string]*Symbol)
+    f.inherits = extends.Nchildren > 0
+
+    classblock := node.Children[2]
+    for _, n := range classblock.Children {
+        if n.NodeType == token.MAKE_FUNC {
+            define(f.members, n.Children[0].Str, SYM_FUNC,
+                   f.pathname, n)
+        } else {
+            collectLocals(f.members, f.pathname, n)
+        }
+    }
+    f.visitChildren(classblock)
+
+    diags := f.diags
+    *f = saved
+    f.diags = diags
+}
+
+/*
+ * Resolve what a call refers to if it can be known
+ * statically: a name, or a member of an imported module.
+ */
+func (f *fileChecker) callee(node *interm.Node) *Symbol {
+    switch node.NodeType {
+        case token.NAME:
+            return f.lookup(node.Str)
+        case token.MEMBER:
+            return f.moduleMember(node)
+    }
+    return nil
+}
+
+func (f *fileChecker) moduleMember(node *interm.Node) *Symbol {
+    obj := node.Children[0]
+    if obj.NodeType != token.NAME {
+        return nil
+    }
+    sym := f.lookup(obj.Str)
+    if sym == nil || sym.Kind != SYM_MODULE || sym.Module == nil {
+        return nil
+    }
+    return sym.Module.Globals[node.Children[1].Str]
+}
+
+func (f *fileChecker) member(node *interm.Node) {
+    obj := node.Children[0]
+    if obj.NodeType != token.NAME {
+        return
+    }
+    sym := f.lookup(obj.Str)
+    if sym == nil || sym.Kind != SYM_MODULE || sym.Module == nil {
+        return
+    }
+    name := node.Children[1].Str
+    if _, ok := sym.Module.Globals[name]; !ok {
+        f.report(node.Children[1], "module '%s' has no member '%s'",
+                 sym.Module.Name, name)
+    }
+}
+
+func (f *fileChecker) call(node *interm.Node, sym *Symbol,
+                           args *interm.Node) {
+    if sym == nil {
+        return
+    }
+    // Keyword arguments come last, see argumentList.
+    var kwarg *interm.Node
+    nargs := args.Nchildren
+    for i, arg := range args.Children {
+        if arg.NodeType == token.KWARG {
+            kwarg, nargs = arg, i
+            break
+        }
+    }
+    switch sym.Kind {
+        case SYM_FUNC:
+            if kwarg != nil {
+                f.report(kwarg, "%s() takes no keyword arguments",
+                         sym.Name)
+                return
+            }
+            f.arity(node, sym.Name, sym.Node.Children[1], nargs, false)
+        case SYM_BUILTIN:
+            fn, ok := sym.Object.(*objects.BlGFunctionObject)
+            if !ok {
+                break
+            }
+            if (fn.Flags & objects.GFUNC_NOARGS) != 0 && nargs > 0 {
+                f.report(node, "%s() takes no arguments", fn.Name)
+            }
+            if (fn.Flags & objects.GFUNC_KEYWORDS) == 0 && kwarg != nil {
+                f.report(kwarg, "%s() takes no keyword arguments",
+                         fn.Name)
+            }
+    }
+}
+
+/*
+ * Check the arguments of 'new' against the '__init__'
+ * of the class. Classes without one ignore arguments,
+ * unless it is inherited.
+ */
+func (f *fileChecker) construct(node *interm.Node, sym *Symbol) {
+    if sym.Node.Children[1].Nchildren > 0 {
+        return
+    }
+    for _, n := range sym.Node.Children[2].Children {
+        if n.NodeType == token.MAKE_FUNC &&
+           n.Children[0].Str == "__init__" {
+            f.arity(node, sym.Name, n.Children[1],
+                    node.Children[1].Nchildren, true)
+            return
+        }
+    }
+}
+
+/*
+ * Same rules as buildLocals: a star parameter takes
+ * the surplus arguments and a receiver takes the first
+ * parameter, unless the star parameter is the only one.
+ */
+func (f *fileChecker) arity(node *interm.Node, name string,
+                            params *interm.Node, nargs int,
+                            self bool) {
+    star := (params.Flags & interm.FLAG_STARPARAM) != 0
+    plain := params.Nchildren
+    if star {
+        plain--
+    }
+    // The receiver is not written by the caller.
+    if self && !(star && plain == 0) {
+        plain--
+    }
+    switch {
+        case star && nargs < plain:
+            f.report(node, "too few arguments in call to '%s'." +
+                     " Expected at least (%d), got (%d)", name,
+                     plain, nargs)
+        case !star && nargs != plain:
+            f.report(node, "argument mismatch in call to '%s'." +
+                     " Expected (%d), got (%d)", name, plain, nargs)
+    }
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+    "os"
+    "fmt"
+    "flag"
+    "bytes"
+    "io/ioutil"
+    "github.com/Magnus9/blue/format"
+)
+
+/*
+ * blue fmt [-w] [-check] [file ...]
+ *
+ * Without files the program is read from stdin and
+ * the result written to stdout. Returns the exit
+ * status of the command.
+ */
+func fmtMain(args []string) int {
+    flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+    write := flags.Bool("w", false, "write result to the source file")
+    check := flags.Bool("check", false, "list files that are not" +
+                        " formatted and exit with status 1")
+    flags.Usage = func() {
+        fmt.Fprintf(os.Stderr, "usage: blue fmt [-w] [-check]" +
+                    " [file ...]\n")
+        flags.PrintDefaults()
+    }
+    if flags.Parse(args) != nil {
+        return 2
+    }
+    if flags.NArg() == 0 {
+        if *write {
+            fmt.Fprintf(os.Stderr, "blue fmt: cannot use -w with" +
+                        " standard input\n")
+            return 2
+        }
+        src, err := ioutil.ReadAll(os.Stdin)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%s\n", err)
+            return 1
+        }
+        return fmtFile("<stdin>", src, false, *check)
+    }
+    status := 0
+    for _, pathname := range flags.Args() {
+        src, err := ioutil.ReadFile(pathname)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%s\n", err)
+            status = 1
+            continue
+        }
+        if ret := fmtFile(pathname, src, *write, *check); ret != 0 {
+            status = ret
+        }
+    }
+    return status
+}
+
+func fmtFile(pathname string, src []byte, write, check bool) int {
+    out, err := format.Source(pathname, src)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%s\n", err)
+        return 1
+    }
+    switch {
+        case check:
+            if !bytes.Equal(src, out) {
+                fmt.Println(pathname)
+                return 1
+            }
+        case write:
+            if bytes.Equal(src, out) {
+                return 0
+            }
+            stat, err := os.Stat(pathname)
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "%s\n", err)
+                return 1
+            }
+            err = ioutil.WriteFile(pathname, out, stat.Mode().Perm())
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "%s\n", err)
+                return 1
+            }
+        default:
+            os.Stdout.Write(out)
+    }
+    return 0
+}
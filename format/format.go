@@ -0,0 +1,455 @@
+/*
+ * Package format prints a parsed blue program back out
+ * as canonical source. Statements get one line each,
+ * blocks are indented with four spaces, operators are
+ * surrounded by single spaces and parentheses are only
+ * kept where precedence needs them. Comments are taken
+ * from the scanner trivia and put back in place by
+ * their line numbers.
+ */
+package format
+
+import (
+    "bytes"
+    "strings"
+    "github.com/Magnus9/blue/token"
+    "github.com/Magnus9/blue/interm"
+    "github.com/Magnus9/blue/parser"
+)
+const INDENT = "    "
+
+// Binding strength of the expression nodes, loosest first.
+const (
+    precPrint = iota
+    precOr
+    precAnd
+    precEqual
+    precComp
+    precRange
+    precBitOr
+    precXor
+    precBitAnd
+    precShift
+    precArith
+    precTerm
+    precUnary
+    precPower
+    precTrailer
+    precAtom
+)
+
+type printer struct {
+    lines      []string
+    indent     int
+    comments   []token.Comment
+    cpos       int
+    // Last source line that has been printed.
+    lastLine   int
+    // Set until the first item of a block is printed.
+    blockStart bool
+    // Source lines of the code on the last printed line,
+    // 0 if it holds none.
+    codeFirst  int
+    codeLast   int
+}
+
+/*
+ * Format the source of a blue program. The pathname
+ * is only used in error messages.
+ */
+func Source(pathname string, src []byte) ([]byte, error) {
+    root, comments, err := parser.ParseWithComments(pathname,
+                                                    string(src))
+    if err != nil {
+        return nil, err
+    }
+    p := &printer{
+        comments  : comments,
+        blockStart: true,
+    }
+    p.stmts(root.Children)
+    p.flushComments(int(^uint(0) >> 1))
+
+    if len(p.lines) == 0 {
+        return []byte{}, nil
+    }
+    var buf bytes.Buffer
+    for _, line := range p.lines {
+        buf.WriteString(line)
+        buf.WriteByte('\n')
+    }
+    return buf.Bytes(), nil
+}
+
+func (p *printer) line(str string) {
+    p.lines = append(p.lines, strings.Repeat(INDENT, p.indent) +
+                     str)
+    p.codeFirst, p.codeLast = 0, 0
+}
+
+// Print a line of code taken from source lines first to last.
+func (p *printer) code(str string, first, last int) {
+    p.line(str)
+    p.codeFirst, p.codeLast = first, last
+}
+
+/*
+ * Emit a single blank line if the item starting at
+ * 'lineNum' was separated from the previous one in the
+ * source. Runs of blank lines collapse into one.
+ */
+func (p *printer) separate(lineNum int) {
+    if !p.blockStart && lineNum > p.lastLine + 1 {
+        p.lines = append(p.lines, "")
+    }
+    p.blockStart = false
+}
+
+func (p *printer) advance(lineNum int) {
+    if lineNum > p.lastLine {
+        p.lastLine = lineNum
+    }
+}
+
+/*
+ * Print every comment in front of the code that starts
+ * on 'lineNum', which takes those that open that line
+ * too. Comments that followed code on their line are put
+ * at the end of the last printed line, if the code there
+ * came from that line.
+ */
+func (p *printer) flushComments(lineNum int) {
+    for p.cpos < len(p.comments) {
+        c := p.comments[p.cpos]
+        if c.LineNum > lineNum || c.LineNum == lineNum && c.Trailing {
+            break
+        }
+        p.cpos++
+        if c.Trailing && c.LineNum >= p.codeFirst &&
+           c.LineNum <= p.codeLast {
+            p.lines[len(p.lines) - 1] += " " + c.Text
+            // Only a long comment that ends on its line leaves
+            // room for more after it.
+            if !c.Long || c.EndLineNum > c.LineNum {
+                p.codeFirst, p.codeLast = 0, 0
+            }
+        } else {
+            p.separate(c.LineNum)
+            p.line(c.Text)
+        }
+        p.advance(c.EndLineNum)
+    }
+}
+
+func firstLine(node *interm.Node) int {
+    line := node.LineNum
+    for _, n := range node.Children {
+        if l := firstLine(n); l > 0 && (line == 0 || l < line) {
+            line = l
+        }
+    }
+    return line
+}
+
+func lastLine(node *interm.Node) int {
+    line := node.LineNum
+    if node.EndLineNum > line {
+        line = node.EndLineNum
+    }
+    for _, n := range node.Children {
+        if l := lastLine(n); l > line {
+            line = l
+        }
+    }
+    return line
+}
+
+func (p *printer) stmts(nodes []*interm.Node) {
+    for _, n := range nodes {
+        start := firstLine(n)
+        p.flushComments(start)
+        p.separate(start)
+        p.stmt(n)
+        p.advance(lastLine(n))
+    }
+}
+
+/*
+ * Print an indented block. Comments in front of
+ * 'closeLine' (the line of the keyword that ends
+ * the block) are kept inside of it.
+ */
+func (p *printer) block(node *interm.Node, closeLine int) {
+    p.indent++
+    p.blockStart = true
+    p.stmts(node.Children)
+    p.flushComments(closeLine)
+    p.blockStart = false
+    p.indent--
+}
+
+// A statement that is printed on a line of its own.
+func (p *printer) simple(str string, node *interm.Node) {
+    p.code(str, firstLine(node), lastLine(node))
+}
+
+func (p *printer) closeBlock(str string, first, last int) {
+    p.code(str, first, last)
+    p.advance(last)
+}
+
+func (p *printer) stmt(node *interm.Node) {
+    switch node.NodeType {
+        case token.MAKE_CLASS:
+            str := "class " + node.Children[0].Str
+            if extends := node.Children[1]; extends.Nchildren > 0 {
+                str += " : " + extends.Children[0].Str
+            }
+            p.code(str, firstLine(node), node.Children[0].LineNum)
+            p.advance(node.Children[0].LineNum)
+
+            classblock := node.Children[2]
+            p.block(classblock, classblock.EndLineNum)
+            p.closeBlock("end", classblock.EndLineNum,
+                         classblock.EndLineNum)
+        case token.MAKE_FUNC:
+            p.code("def " + node.Children[0].Str +
+                   params(node.Children[1]), firstLine(node),
+                   lastLine(node.Children[1]))
+            p.advance(lastLine(node.Children[1]))
+            p.block(node.Children[2], node.EndLineNum)
+            p.closeBlock("end", node.EndLineNum, node.EndLineNum)
+        case token.IF:
+            p.code("if " + expr(node.Children[0], precPrint) +
+                   " then", firstLine(node), lastLine(node.Children[0]))
+            p.advance(lastLine(node.Children[0]))
+            i := 1
+            for i < node.Nchildren {
+                block := node.Children[i]
+                i++
+                if i < node.Nchildren &&
+                   node.Children[i].NodeType == token.ELIF {
+                    elif := node.Children[i]
+                    cond := node.Children[i + 1]
+                    p.block(block, elif.LineNum)
+                    p.closeBlock("elif " + expr(cond, precPrint) +
+                                 " then", elif.LineNum, lastLine(cond))
+                    i += 2
+                } else if i < node.Nchildren {
+                    elseBlock := node.Children[i]
+                    p.block(block, elseBlock.LineNum)
+                    p.closeBlock("else", elseBlock.LineNum,
+                                 elseBlock.LineNum)
+                } else {
+                    p.block(block, node.EndLineNum)
+                }
+            }
+            p.closeBlock("end", node.EndLineNum, node.EndLineNum)
+        case token.WHILE:
+            last := node.Nchildren - 1
+            parts := make([]string, 0, last)
+            for _, n := range node.Children[:last] {
+                parts = append(parts, exprStmt(n))
+            }
+            p.code("while " + strings.Join(parts, ", ") + " do",
+                   firstLine(node), lastLine(node.Children[last - 1]))
+            p.advance(lastLine(node.Children[last - 1]))
+            p.block(node.Children[last], node.EndLineNum)
+            p.closeBlock("end", node.EndLineNum, node.EndLineNum)
+        case token.FOR:
+            p.code("for " + node.Children[0].Str + " in " +
+                   expr(node.Children[1], precPrint) + " do",
+                   firstLine(node), lastLine(node.Children[1]))
+            p.advance(lastLine(node.Children[1]))
+            p.block(node.Children[2], node.EndLineNum)
+            p.closeBlock("end", node.EndLineNum, node.EndLineNum)
+        case token.RETURN:
+            if node.Nchildren > 0 {
+                p.simple("return " + expr(node.Children[0], precPrint), node)
+            } else {
+                p.simple("return", node)
+            }
+        case token.BREAK:
+            p.simple("break", node)
+        case token.CONTINUE:
+            p.simple("continue", node)
+        case token.IMPORT:
+            paths := make([]string, 0, node.Nchildren)
+            for _, path := range node.Children {
+                names := make([]string, 0, path.Nchildren)
+                for _, n := range path.Children {
+                    names = append(names, n.Str)
+                }
+                paths = append(paths, strings.Join(names, "."))
+            }
+            p.simple("import " + strings.Join(paths, ", "), node)
+        default:
+            p.simple(exprStmt(node), node)
+    }
+}
+
+func params(node *interm.Node) string {
+    names := make([]string, 0, node.Nchildren)
+    for i, n := range node.Children {
+        if i == node.Nchildren - 1 &&
+           (node.Flags & interm.FLAG_STARPARAM) != 0 {
+            names = append(names, "*" + n.Str)
+        } else {
+            names = append(names, n.Str)
+        }
+    }
+    return "(" + strings.Join(names, ", ") + ")"
+}
+
+/*
+ * Assignments are statements, but they can also show
+ * up in the trailing expression list of a while loop.
+ */
+func exprStmt(node *interm.Node) string {
+    switch node.NodeType {
+        case token.ASSIGN:
+            return expr(node.Children[0], precPrint) + " = " +
+                   expr(node.Children[1], precPrint)
+        case token.AUGASSIGN:
+            op := node.Children[0]
+            return expr(op.Children[0], precPrint) + " " + op.Str +
+                   " " + expr(op.Children[1], precPrint)
+    }
+    return expr(node, precPrint)
+}
+
+func precedence(node *interm.Node) int {
+    switch node.NodeType {
+        case token.PRINT:
+            return precPrint
+        case token.RANGE:
+            return precRange
+        case token.LOGICAL_OR:
+            return precOr
+        case token.LOGICAL_AND:
+            return precAnd
+        case token.COMP_OP:
+            switch node.Children[0].NodeType {
+                case token.EQ, token.NE:
+                    return precEqual
+            }
+            return precComp
+        case token.BITWISE_OR:
+            return precBitOr
+        case token.XOR:
+            return precXor
+        case token.BITWISE_AND:
+            return precBitAnd
+        case token.LEFTSHIFT, token.RIGHTSHIFT:
+            return precShift
+        case token.ADD, token.SUB:
+            return precArith
+        case token.MUL, token.DIV, token.MODULO, token.FLOORDIV:
+            return precTerm
+        case token.NEGATE, token.NOT, token.COMPL:
+            return precUnary
+        case token.POWER:
+            return precPower
+        case token.SUBSCRIPT, token.CALL, token.MEMBER:
+            return precTrailer
+    }
+    return precAtom
+}
+
+/*
+ * Print an expression in a context that binds at least
+ * as hard as 'min'. Looser expressions get wrapped in
+ * parentheses.
+ */
+func expr(node *interm.Node, min int) string {
+    str := rawExpr(node)
+    if precedence(node) < min {
+        return "(" + str + ")"
+    }
+    return str
+}
+
+func binary(node *interm.Node, op string) string {
+    prec := precedence(node)
+    return expr(node.Children[0], prec) + " " + op + " " +
+           expr(node.Children[1], prec + 1)
+}
+
+func exprList(nodes []*interm.Node) string {
+    list := make([]string, 0, len(nodes))
+    for _, n := range nodes {
+        list = append(list, expr(n, precPrint))
+    }
+    return strings.Join(list, ", ")
+}
+
+func rawExpr(node *interm.Node) string {
+    switch node.NodeType {
+        case token.PRINT:
+            return "print " + expr(node.Children[0], precPrint)
+        case token.RANGE:
+            var lhs, rhs string
+            pos := 0
+            if (node.Flags & interm.FLAG_RANGELHS) != 0 {
+                lhs = expr(node.Children[pos], precBitOr)
+                pos++
+            }
+            if (node.Flags & interm.FLAG_RANGERHS) != 0 {
+                rhs = expr(node.Children[pos], precBitOr)
+                pos++
+            }
+            if (node.Flags & interm.FLAG_RANGESTEP) != 0 {
+                rhs += ".." + expr(node.Children[pos], precBitOr)
+            }
+            return lhs + node.Str + rhs
+        case token.COMP_OP:
+            op := node.Children[0]
+            prec := precedence(node)
+            return expr(op.Children[0], prec) + " " + op.Str + " " +
+                   expr(op.Children[1], prec + 1)
+        case token.LOGICAL_OR, token.LOGICAL_AND, token.BITWISE_OR,
+             token.XOR, token.BITWISE_AND, token.LEFTSHIFT,
+             token.RIGHTSHIFT, token.ADD, token.SUB, token.MUL,
+             token.DIV, token.MODULO, token.FLOORDIV:
+            return binary(node, node.Str)
+        case token.POWER:
+            // Groups to the right and takes unary operands there.
+            return expr(node.Children[0], precTrailer) + " ** " +
+                   expr(node.Children[1], precUnary)
+        case token.NEGATE, token.NOT, token.COMPL:
+            return node.Str + expr(node.Children[0], precUnary)
+        case token.SUBSCRIPT:
+            return expr(node.Children[0], precTrailer) + "[" +
+                   expr(node.Children[1], precPrint) + "]"
+        case token.CALL:
+            return expr(node.Children[0], precTrailer) + "(" +
+                   exprList(node.Children[1].Children) + ")"
+        case token.KWARG:
+            return node.Str + " = " + expr(node.Children[0], precPrint)
+        case token.MEMBER:
+            return expr(node.Children[0], precTrailer) + "." +
+                   node.Children[1].Str
+        case token.MAKE_INSTANCE:
+            return "new " + node.Children[0].Str + "(" +
+                   exprList(node.Children[1].Children) + ")"
+        case token.LIST:
+            return "[" + exprList(node.Children) + "]"
+        case token.SET:
+            return "{" + exprList(node.Children) + "}"
+        case token.TUPLE:
+            if node.Nchildren == 1 {
+                return "(" + exprList(node.Children) + ",)"
+            }
+            return "(" + exprList(node.Children) + ")"
+        case token.HASH:
+            elems := make([]string, 0, node.Nchildren)
+            for _, elem := range node.Children {
+                elems = append(elems,
+                    expr(elem.Children[0], precPrint) + " => " +
+                    expr(elem.Children[1], precPrint))
+            }
+            return "{" + strings.Join(elems, ", ") + "}"
+    }
+    // Names and literals are printed the way they were written.
+    return node.Str
+}
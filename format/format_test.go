@@ -0,0 +1,34 @@
+package format
+
+import "testing"
+
+var idempotentSources = []string{
+    "x = foo(1,\n        2) # trailing\ny = 2\n",
+    "x = foo(1, === long\ncomment === 2) # after\ny = 1\n",
+    "x = foo(1,\n=== alone ===\n        2) === trailing ===\ny = 2\n",
+    "if a then\n    x = 1\nelse === on else\n=== y = 1\nend\n",
+    "=== leading === x = 1\n",
+    "while i < === a\nb === 3, i += === c\nd === 1 do\n    print i\nend\n",
+    "q = === a\nb === new A(1) === c ===\nr = 5\n",
+    "return [1, # one\n        2] === two\nthree ===\n",
+}
+
+// Formatting formatted source must not change it again.
+func TestIdempotent(t *testing.T) {
+    for _, src := range idempotentSources {
+        once, err := Source("test.bl", []byte(src))
+        if err != nil {
+            t.Errorf("%q: %v", src, err)
+            continue
+        }
+        twice, err := Source("test.bl", once)
+        if err != nil {
+            t.Errorf("%q: formatted source fails: %v", src, err)
+            continue
+        }
+        if string(once) != string(twice) {
+            t.Errorf("%q: formatted twice as\n%s\nthen\n%s", src,
+                     once, twice)
+        }
+    }
+}
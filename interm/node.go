@@ -9,15 +9,22 @@ const (
     FLAG_STARPARAM = 1 << 0
     FLAG_RANGELHS  = 1 << 0
     FLAG_RANGERHS  = 1 << 1
+    FLAG_RANGESTEP = 1 << 2
 )
 type Node struct {
-    Str       string
-    Line      string
-    NodeType  int
-    LineNum   int
-    Nchildren int
-    Flags     int
-    Children  []*Node
+    Str        string
+    Line       string
+    NodeType   int
+    LineNum    int
+    // Line of the token closing the construct ('end', ')'..), 0 if none.
+    EndLineNum int
+    // Columns of the node's text on LineNum, counting from 1.
+    // EndCol is the column after the last one.
+    Col        int
+    EndCol     int
+    Nchildren  int
+    Flags      int
+    Children   []*Node
 }
 
 func New(str, line string, nodeType, lineNum int) *Node {
@@ -33,6 +40,24 @@ func New(str, line string, nodeType, lineNum int) *Node {
 func (n *Node) Add(node *Node) {
     n.Children = append(n.Children, node)
     n.Nchildren++
+    n.Extend(node.LineNum, node.Col, node.EndCol)
+}
+
+/*
+ * Widen the columns of the node to cover col..endCol,
+ * if they are on the line the node is on. Children are
+ * covered as they are added.
+ */
+func (n *Node) Extend(lineNum, col, endCol int) {
+    if lineNum != n.LineNum || col == 0 {
+        return
+    }
+    if n.Col == 0 || col < n.Col {
+        n.Col = col
+    }
+    if endCol > n.EndCol {
+        n.EndCol = endCol
+    }
 }
 
 func (n *Node) GiveRootTo(node *Node) *Node {
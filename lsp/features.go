@@ -0,0 +1,318 @@
+package lsp
+
+import (
+    "sort"
+    "strings"
+    "github.com/Magnus9/blue/token"
+    "github.com/Magnus9/blue/interm"
+    "github.com/Magnus9/blue/checker"
+    "github.com/Magnus9/blue/objects"
+)
+
+func isNameChar(c byte) bool {
+    return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+           c >= '0' && c <= '9'
+}
+
+/*
+ * The name under the cursor and the name in front of it
+ * if it is written as 'qualifier.name'. The name ends at
+ * the cursor when 'prefix' is set, as is wanted for
+ * completion.
+ */
+func (doc *document) wordAt(pos Position,
+                            prefix bool) (qualifier, name string) {
+    text := doc.line(pos.Line)
+    col := pos.Character
+    if col > len(text) {
+        col = len(text)
+    }
+    start, end := col, col
+    for start > 0 && isNameChar(text[start - 1]) {
+        start--
+    }
+    if !prefix {
+        for end < len(text) && isNameChar(text[end]) {
+            end++
+        }
+    }
+    name = text[start:end]
+    if start > 0 && text[start - 1] == '.' {
+        qstart := start - 1
+        for qstart > 0 && isNameChar(text[qstart - 1]) {
+            qstart--
+        }
+        qualifier = text[qstart:start - 1]
+    }
+    return qualifier, name
+}
+
+func (doc *document) scope(pos Position) *checker.Scope {
+    if doc.root == nil {
+        return nil
+    }
+    return doc.checker.ScopeAt(doc.pathname, doc.root, pos.Line + 1)
+}
+
+/*
+ * Find the symbol the name under the cursor refers to.
+ * Members of imported modules are resolved through the
+ * module's globals.
+ */
+func (doc *document) resolve(pos Position) *checker.Symbol {
+    scope := doc.scope(pos)
+    if scope == nil {
+        return nil
+    }
+    qualifier, name := doc.wordAt(pos, false)
+    if name == "" {
+        return nil
+    }
+    if qualifier == "" {
+        return scope.Lookup(name)
+    }
+    sym := scope.Lookup(qualifier)
+    if sym == nil || sym.Kind != checker.SYM_MODULE ||
+       sym.Module == nil || sym.Module.Globals == nil {
+        return nil
+    }
+    return sym.Module.Globals[name]
+}
+
+func (s *Server) definition(doc *document,
+                            pos Position) []Location {
+    sym := doc.resolve(pos)
+    if sym == nil || sym.Kind == checker.SYM_BUILTIN {
+        return []Location{}
+    }
+    if sym.Kind == checker.SYM_MODULE {
+        if sym.Module == nil || sym.Module.Root == nil {
+            return []Location{}
+        }
+        return []Location{{URI: pathToURI(sym.Module.Pathname)}}
+    }
+    line := sym.Node.LineNum - 1
+    var text string
+    if other, ok := s.docs[pathToURI(sym.Pathname)]; ok {
+        text = other.line(line)
+    } else if sym.Pathname == doc.pathname {
+        text = doc.line(line)
+    } else {
+        text = sourceLine(sym.Pathname, line)
+    }
+    col := nameColumn(text, sym.Name)
+    return []Location{{
+        URI  : pathToURI(sym.Pathname),
+        Range: Range{
+            Start: Position{line, col},
+            End  : Position{line, col + len(sym.Name)},
+        },
+    }}
+}
+
+// The column where 'name' is written as a whole word.
+func nameColumn(text, name string) int {
+    for off := 0; off < len(text); {
+        i := strings.Index(text[off:], name)
+        if i < 0 {
+            break
+        }
+        i += off
+        end := i + len(name)
+        if (i == 0 || !isNameChar(text[i - 1])) &&
+           (end == len(text) || !isNameChar(text[end])) {
+            return i
+        }
+        off = end
+    }
+    return 0
+}
+
+func (s *Server) hover(doc *document, pos Position) *hover {
+    sym := doc.resolve(pos)
+    if sym == nil {
+        return nil
+    }
+    var str string
+    switch sym.Kind {
+        case checker.SYM_FUNC:
+            str = checker.Signature(sym.Node)
+        case checker.SYM_CLASS:
+            str = "class " + sym.Name
+            if extends := sym.Node.Children[1]; extends.Nchildren > 0 {
+                str += " : " + extends.Children[0].Str
+            }
+            if init := classInit(sym.Node); init != nil {
+                str += "\n" + checker.Signature(init)
+            }
+        case checker.SYM_MODULE:
+            str = "import " + sym.Name
+            if sym.Module != nil {
+                str += "\n# " + sym.Module.Pathname
+            }
+        case checker.SYM_BUILTIN:
+            str = builtinRepr(sym.Object)
+        case checker.SYM_PARAM:
+            str = "(parameter) " + sym.Name
+        default:
+            str = sym.Name
+    }
+    return &hover{markupContent{
+        Kind : "markdown",
+        Value: "```blue\n" + str + "\n```",
+    }}
+}
+
+func classInit(node *interm.Node) *interm.Node {
+    for _, n := range node.Children[2].Children {
+        if n.NodeType == token.MAKE_FUNC &&
+           n.Children[0].Str == "__init__" {
+            return n
+        }
+    }
+    return nil
+}
+
+func builtinRepr(obj objects.BlObject) string {
+    typeobj := obj.BlType()
+    if typeobj == nil || typeobj.Repr == nil {
+        return ""
+    }
+    return typeobj.Repr(obj).Value
+}
+
+func (s *Server) completion(doc *document,
+                            pos Position) []CompletionItem {
+    items := []CompletionItem{}
+    scope := doc.scope(pos)
+    if scope == nil {
+        return items
+    }
+    qualifier, name := doc.wordAt(pos, true)
+    text := doc.line(pos.Line)
+    col := pos.Character
+    if col > len(text) {
+        col = len(text)
+    }
+    // The receiver can be any expression, i.e "abc".upper.
+    before := text[:col - len(name)]
+    if !strings.HasSuffix(before, ".") {
+        for _, sym := range scope.Symbols() {
+            items = append(items, symbolItem(sym))
+        }
+        for word := range token.RES_WORDS {
+            items = append(items, CompletionItem{
+                Label: word,
+                Kind : KIND_KEYWORD,
+            })
+        }
+        return items
+    }
+    sym := scope.Lookup(qualifier)
+    if sym != nil && sym.Kind == checker.SYM_MODULE {
+        if sym.Module != nil {
+            names := make([]string, 0, len(sym.Module.Globals))
+            for name := range sym.Module.Globals {
+                names = append(names, name)
+            }
+            sort.Strings(names)
+            for _, name := range names {
+                items = append(items,
+                               symbolItem(sym.Module.Globals[name]))
+            }
+        }
+        return items
+    }
+    for _, name := range memberNames(scope, sym) {
+        items = append(items, CompletionItem{
+            Label: name,
+            Kind : KIND_METHOD,
+        })
+    }
+    return items
+}
+
+func symbolItem(sym *checker.Symbol) CompletionItem {
+    item := CompletionItem{Label: sym.Name}
+    switch sym.Kind {
+        case checker.SYM_FUNC:
+            item.Kind = KIND_FUNCTION
+            item.Detail = checker.Signature(sym.Node)
+        case checker.SYM_CLASS:
+            item.Kind = KIND_CLASS
+        case checker.SYM_MODULE:
+            item.Kind = KIND_MODULE
+        case checker.SYM_BUILTIN:
+            switch sym.Object.(type) {
+                case *objects.BlTypeObject:
+                    item.Kind = KIND_CLASS
+                case *objects.BlModuleObject:
+                    item.Kind = KIND_MODULE
+                default:
+                    item.Kind = KIND_FUNCTION
+            }
+            item.Detail = builtinRepr(sym.Object)
+        default:
+            item.Kind = KIND_VARIABLE
+    }
+    return item
+}
+
+/*
+ * The members of the builtin type a variable holds, if
+ * the value it was assigned tells. Otherwise every member
+ * of the builtin types is offered.
+ */
+func memberNames(scope *checker.Scope, sym *checker.Symbol) []string {
+    if typeobj := valueType(scope, sym); typeobj != nil {
+        return objects.BlMemberNames(typeobj)
+    }
+    seen := make(map[string]struct{})
+    var names []string
+    for _, b := range scope.Builtins {
+        typeobj, ok := b.Object.(*objects.BlTypeObject)
+        if !ok {
+            continue
+        }
+        for _, name := range objects.BlMemberNames(typeobj) {
+            if _, ok := seen[name]; !ok {
+                seen[name] = struct{}{}
+                names = append(names, name)
+            }
+        }
+    }
+    sort.Strings(names)
+    return names
+}
+
+func valueType(scope *checker.Scope,
+               sym *checker.Symbol) *objects.BlTypeObject {
+    if sym == nil || sym.Value == nil {
+        return nil
+    }
+    switch value := sym.Value; value.NodeType {
+        case token.STRING, token.INTERP:
+            return &objects.BlStringType
+        case token.INTEGER:
+            return &objects.BlIntType
+        case token.FLOAT:
+            return &objects.BlFloatType
+        case token.LIST:
+            return &objects.BlListType
+        case token.HASH:
+            return &objects.BlMapType
+        case token.SET:
+            return &objects.BlSetType
+        case token.TUPLE:
+            return &objects.BlTupleType
+        case token.RANGE:
+            return &objects.BlRangeType
+        case token.MAKE_INSTANCE:
+            b := scope.Lookup(value.Children[0].Str)
+            if b != nil && b.Kind == checker.SYM_BUILTIN {
+                typeobj, _ := b.Object.(*objects.BlTypeObject)
+                return typeobj
+            }
+    }
+    return nil
+}
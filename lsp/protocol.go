@@ -0,0 +1,138 @@
+/*
+ * The parts of the Language Server Protocol the server
+ * speaks. Field names follow the specification.
+ */
+package lsp
+
+import "encoding/json"
+
+const (
+    // JSON-RPC error codes.
+    PARSE_ERROR      = -32700
+    INVALID_REQUEST  = -32600
+    METHOD_NOT_FOUND = -32601
+    INVALID_PARAMS   = -32602
+
+    SEVERITY_ERROR   = 1
+    SEVERITY_WARNING = 2
+
+    // CompletionItemKind values.
+    KIND_METHOD   = 2
+    KIND_FUNCTION = 3
+    KIND_VARIABLE = 6
+    KIND_CLASS    = 7
+    KIND_MODULE   = 9
+    KIND_KEYWORD  = 14
+
+    SYNC_FULL = 1
+)
+
+type request struct {
+    JSONRPC string           `json:"jsonrpc"`
+    ID      *json.RawMessage `json:"id,omitempty"`
+    Method  string           `json:"method"`
+    Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type response struct {
+    JSONRPC string           `json:"jsonrpc"`
+    ID      *json.RawMessage `json:"id"`
+    Result  json.RawMessage  `json:"result,omitempty"`
+    Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+}
+
+type notification struct {
+    JSONRPC string      `json:"jsonrpc"`
+    Method  string      `json:"method"`
+    Params  interface{} `json:"params"`
+}
+
+type Position struct {
+    Line      int `json:"line"`
+    Character int `json:"character"`
+}
+
+type Range struct {
+    Start Position `json:"start"`
+    End   Position `json:"end"`
+}
+
+type Location struct {
+    URI   string `json:"uri"`
+    Range Range  `json:"range"`
+}
+
+type Diagnostic struct {
+    Range    Range  `json:"range"`
+    Severity int    `json:"severity"`
+    Source   string `json:"source"`
+    Message  string `json:"message"`
+}
+
+type publishDiagnosticsParams struct {
+    URI         string       `json:"uri"`
+    Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type textDocumentItem struct {
+    URI  string `json:"uri"`
+    Text string `json:"text"`
+}
+
+type textDocumentIdentifier struct {
+    URI string `json:"uri"`
+}
+
+type didOpenParams struct {
+    TextDocument textDocumentItem `json:"textDocument"`
+}
+
+type didChangeParams struct {
+    TextDocument   textDocumentIdentifier `json:"textDocument"`
+    ContentChanges []struct {
+        Text string `json:"text"`
+    } `json:"contentChanges"`
+}
+
+type didCloseParams struct {
+    TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+type textDocumentPositionParams struct {
+    TextDocument textDocumentIdentifier `json:"textDocument"`
+    Position     Position               `json:"position"`
+}
+
+type markupContent struct {
+    Kind  string `json:"kind"`
+    Value string `json:"value"`
+}
+
+type hover struct {
+    Contents markupContent `json:"contents"`
+}
+
+type CompletionItem struct {
+    Label  string `json:"label"`
+    Kind   int    `json:"kind,omitempty"`
+    Detail string `json:"detail,omitempty"`
+}
+
+type initializeResult struct {
+    Capabilities struct {
+        TextDocumentSync   int  `json:"textDocumentSync"`
+        DefinitionProvider bool `json:"definitionProvider"`
+        HoverProvider      bool `json:"hoverProvider"`
+        CompletionProvider struct {
+            TriggerCharacters []string `json:"triggerCharacters"`
+        } `json:"completionProvider"`
+    } `json:"capabilities"`
+    ServerInfo struct {
+        Name string `json:"name"`
+    } `json:"serverInfo"`
+}
@@ -0,0 +1,377 @@
+/*
+ * Package lsp implements a language server for blue
+ * that talks JSON-RPC over stdio. It publishes the
+ * diagnostics of the parser and the static checker, and
+ * answers definition, hover and completion requests
+ * from the checker's scopes.
+ */
+package lsp
+
+import (
+    "io"
+    "fmt"
+    "bufio"
+    "bytes"
+    "errors"
+    "strconv"
+    "strings"
+    "net/url"
+    "io/ioutil"
+    "path/filepath"
+    "encoding/json"
+    "github.com/Magnus9/blue/interm"
+    "github.com/Magnus9/blue/parser"
+    "github.com/Magnus9/blue/checker"
+)
+
+type document struct {
+    uri      string
+    pathname string
+    text     string
+    lines    []string
+    // The last tree that parsed, kept while the text is broken.
+    root     *interm.Node
+    checker  *checker.Checker
+}
+
+type Server struct {
+    in       *bufio.Reader
+    out      io.Writer
+    docs     map[string]*document
+    shutdown bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+    return &Server{
+        in  : bufio.NewReader(in),
+        out : out,
+        docs: make(map[string]*document),
+    }
+}
+
+/*
+ * Serve requests until the client sends 'exit' or
+ * closes the stream. Returns the exit status the
+ * protocol asks for.
+ */
+func (s *Server) Run() int {
+    for {
+        body, err := s.read()
+        if err != nil {
+            if err == io.EOF {
+                return 1
+            }
+            s.reply(nil, nil, &responseError{PARSE_ERROR, err.Error()})
+            continue
+        }
+        var req request
+        if err := json.Unmarshal(body, &req); err != nil {
+            s.reply(nil, nil, &responseError{PARSE_ERROR, err.Error()})
+            continue
+        }
+        if req.Method == "exit" {
+            if s.shutdown {
+                return 0
+            }
+            return 1
+        }
+        result, rerr := s.dispatch(&req)
+        // Notifications have no id and get no response.
+        if req.ID != nil {
+            s.reply(req.ID, result, rerr)
+        }
+    }
+}
+
+func (s *Server) read() ([]byte, error) {
+    length := -1
+    for {
+        line, err := s.in.ReadString('\n')
+        if err != nil {
+            return nil, err
+        }
+        line = strings.TrimRight(line, "\r\n")
+        if line == "" {
+            break
+        }
+        if pos := strings.IndexByte(line, ':'); pos > 0 {
+            name := strings.TrimSpace(line[:pos])
+            if strings.EqualFold(name, "Content-Length") {
+                length, err = strconv.Atoi(strings.TrimSpace(line[pos + 1:]))
+                if err != nil {
+                    return nil, errors.New("bad Content-Length header")
+                }
+            }
+        }
+    }
+    if length < 0 {
+        return nil, errors.New("missing Content-Length header")
+    }
+    body := make([]byte, length)
+    if _, err := io.ReadFull(s.in, body); err != nil {
+        return nil, err
+    }
+    return body, nil
+}
+
+func (s *Server) write(msg interface{}) {
+    body, err := json.Marshal(msg)
+    if err != nil {
+        return
+    }
+    var buf bytes.Buffer
+    fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n", len(body))
+    buf.Write(body)
+    s.out.Write(buf.Bytes())
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{},
+                       rerr *responseError) {
+    resp := response{
+        JSONRPC: "2.0",
+        ID     : id,
+        Error  : rerr,
+    }
+    if rerr == nil {
+        raw, err := json.Marshal(result)
+        if err != nil {
+            raw = []byte("null")
+        }
+        resp.Result = raw
+    }
+    s.write(resp)
+}
+
+func (s *Server) notify(method string, params interface{}) {
+    s.write(notification{
+        JSONRPC: "2.0",
+        Method : method,
+        Params : params,
+    })
+}
+
+func (s *Server) dispatch(req *request) (interface{}, *responseError) {
+    switch req.Method {
+        case "initialize":
+            var result initializeResult
+            caps := &result.Capabilities
+            caps.TextDocumentSync   = SYNC_FULL
+            caps.DefinitionProvider = true
+            caps.HoverProvider      = true
+            caps.CompletionProvider.TriggerCharacters = []string{"."}
+            result.ServerInfo.Name = "blue"
+            return result, nil
+        case "initialized":
+            return nil, nil
+        case "shutdown":
+            s.shutdown = true
+            return nil, nil
+        case "textDocument/didOpen":
+            var params didOpenParams
+            if err := json.Unmarshal(req.Params, &params); err != nil {
+                return nil, invalidParams(err)
+            }
+            s.update(params.TextDocument.URI, params.TextDocument.Text)
+            return nil, nil
+        case "textDocument/didChange":
+            var params didChangeParams
+            if err := json.Unmarshal(req.Params, &params); err != nil {
+                return nil, invalidParams(err)
+            }
+            changes := params.ContentChanges
+            if len(changes) > 0 {
+                s.update(params.TextDocument.URI,
+                         changes[len(changes) - 1].Text)
+            }
+            return nil, nil
+        case "textDocument/didClose":
+            var params didCloseParams
+            if err := json.Unmarshal(req.Params, &params); err != nil {
+                return nil, invalidParams(err)
+            }
+            delete(s.docs, params.TextDocument.URI)
+            s.publish(params.TextDocument.URI, []Diagnostic{})
+            return nil, nil
+        case "textDocument/didSave":
+            return nil, nil
+        case "textDocument/definition":
+            doc, pos, rerr := s.position(req)
+            if rerr != nil {
+                return nil, rerr
+            }
+            return s.definition(doc, pos), nil
+        case "textDocument/hover":
+            doc, pos, rerr := s.position(req)
+            if rerr != nil {
+                return nil, rerr
+            }
+            return s.hover(doc, pos), nil
+        case "textDocument/completion":
+            doc, pos, rerr := s.position(req)
+            if rerr != nil {
+                return nil, rerr
+            }
+            return s.completion(doc, pos), nil
+    }
+    if req.ID == nil || strings.HasPrefix(req.Method, "$/") {
+        return nil, nil
+    }
+    return nil, &responseError{METHOD_NOT_FOUND,
+                               "method not supported: " + req.Method}
+}
+
+func invalidParams(err error) *responseError {
+    return &responseError{INVALID_PARAMS, err.Error()}
+}
+
+func (s *Server) position(req *request) (*document, Position,
+                                         *responseError) {
+    var params textDocumentPositionParams
+    if err := json.Unmarshal(req.Params, &params); err != nil {
+        return nil, Position{}, invalidParams(err)
+    }
+    doc, ok := s.docs[params.TextDocument.URI]
+    if !ok {
+        return nil, Position{}, &responseError{INVALID_PARAMS,
+                                               "unknown document"}
+    }
+    return doc, params.Position, nil
+}
+
+func uriToPath(uri string) string {
+    u, err := url.Parse(uri)
+    if err != nil || u.Scheme != "file" {
+        return uri
+    }
+    return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(pathname string) string {
+    abs, err := filepath.Abs(pathname)
+    if err != nil {
+        abs = pathname
+    }
+    u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+    return u.String()
+}
+
+/*
+ * Reparse a document after it changed and publish the
+ * new diagnostics. A document that fails to parse keeps
+ * the last tree that did, so queries keep working.
+ */
+func (s *Server) update(uri, text string) {
+    doc, ok := s.docs[uri]
+    if !ok {
+        doc = &document{
+            uri     : uri,
+            pathname: uriToPath(uri),
+        }
+        s.docs[uri] = doc
+    }
+    doc.text  = text
+    doc.lines = strings.Split(text, "\n")
+    doc.checker = checker.New()
+
+    diags := []Diagnostic{}
+    root, err := checker.Parse(doc.pathname, []byte(text))
+    // The tree is kept even with errors, it has the rest.
+    if root != nil {
+        doc.root = root
+    }
+    if errs, ok := err.(parser.ErrorList); ok {
+        for _, e := range errs {
+            diags = append(diags, doc.parseDiagnostic(e))
+        }
+    } else if err != nil {
+        diags = append(diags, Diagnostic{
+            Range   : doc.lineRange(0),
+            Severity: SEVERITY_ERROR,
+            Source  : "blue",
+            Message : err.Error(),
+        })
+    } else {
+        for _, d := range doc.checker.CheckTree(doc.pathname, root) {
+            diags = append(diags, Diagnostic{
+                Range   : doc.columnRange(d.LineNum - 1, d.Col,
+                                          d.EndCol),
+                Severity: SEVERITY_WARNING,
+                Source  : "blue check",
+                Message : d.Message,
+            })
+        }
+    }
+    s.publish(uri, diags)
+}
+
+func (s *Server) publish(uri string, diags []Diagnostic) {
+    s.notify("textDocument/publishDiagnostics",
+             publishDiagnosticsParams{uri, diags})
+}
+
+// Parse errors point at the token they are about.
+func (doc *document) parseDiagnostic(e *parser.ParseError) Diagnostic {
+    r := doc.columnRange(e.LineNum - 1, e.Col, e.EndCol)
+    msg := e.Message
+    if e.Found != "" {
+        msg = "unexpected " + e.Found + ", " + msg
+    }
+    return Diagnostic{
+        Range   : r,
+        Severity: SEVERITY_ERROR,
+        Source  : "blue",
+        Message : msg,
+    }
+}
+
+func (doc *document) line(n int) string {
+    if n < 0 || n >= len(doc.lines) {
+        return ""
+    }
+    return strings.TrimRight(doc.lines[n], "\r")
+}
+
+// A line of a file that is not open in the editor.
+func sourceLine(pathname string, n int) string {
+    src, err := ioutil.ReadFile(pathname)
+    if err != nil {
+        return ""
+    }
+    doc := &document{lines: strings.Split(string(src), "\n")}
+    return doc.line(n)
+}
+
+/*
+ * The range of the columns col up to endCol on a line,
+ * as they are counted by the scanner. Falls back to the
+ * whole line when they are not known.
+ */
+func (doc *document) columnRange(n, col, endCol int) Range {
+    text := doc.line(n)
+    if col <= 0 || col - 1 > len(text) {
+        return doc.lineRange(n)
+    }
+    if endCol <= col {
+        endCol = col + 1
+    }
+    if endCol - 1 > len(text) {
+        endCol = len(text) + 1
+    }
+    return Range{
+        Start: Position{n, col - 1},
+        End  : Position{n, endCol - 1},
+    }
+}
+
+// The range covering the text of a line, without indentation.
+func (doc *document) lineRange(n int) Range {
+    if n < 0 {
+        n = 0
+    }
+    text := doc.line(n)
+    start := len(text) - len(strings.TrimLeft(text, " \t"))
+    return Range{
+        Start: Position{n, start},
+        End  : Position{n, len(text)},
+    }
+}
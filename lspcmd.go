@@ -0,0 +1,20 @@
+package main
+
+import (
+    "os"
+    "fmt"
+    "github.com/Magnus9/blue/lsp"
+)
+
+/*
+ * blue lsp
+ *
+ * Runs the language server on stdin and stdout.
+ */
+func lspMain(args []string) int {
+    if len(args) > 0 {
+        fmt.Fprintf(os.Stderr, "usage: blue lsp\n")
+        return 2
+    }
+    return lsp.NewServer(os.Stdin, os.Stdout).Run()
+}
@@ -4,34 +4,126 @@ package main
 import (
     "os"
     "fmt"
+    "flag"
     "github.com/Magnus9/blue/parser"
     "github.com/Magnus9/blue/repl"
     "github.com/Magnus9/blue/objects"
     "github.com/Magnus9/blue/blue"
 )
 
+/*
+ * Subcommands that work on source files instead of
+ * running them. They return the exit status.
+ */
+var commands = map[string]func([]string) int{
+    "fmt"  : fmtMain,
+    "check": checkMain,
+    "lsp"  : lspMain,
+}
+
 func main() {
-    blue.Init(os.Args[1:])
-    globals := make(map[string]objects.BlObject, 0)
-    
     if len(os.Args) > 1 {
-        pathname := os.Args[1]
-        f, err := os.Open(pathname)
-        if err != nil {
-            fmt.Fprintf(os.Stderr, "%s\n", err)
-            return
+        if cmd, ok := commands[os.Args[1]]; ok {
+            os.Exit(cmd(os.Args[2:]))
         }
+    }
+    // Options go in front of the script, the rest is its argv.
+    var opts options
+    flags := flag.NewFlagSet("blue", flag.ExitOnError)
+    flags.Usage = func() {
+        fmt.Fprintf(os.Stderr, "usage: blue [options] [script" +
+                    " [args ...]]\n")
+        flags.PrintDefaults()
+    }
+    flags.StringVar(&opts.profile, "profile", "", "write a profile" +
+                    " of the script to `file`, a flat table if it" +
+                    " ends in .txt or is -")
+    flags.BoolVar(&opts.cover, "cover", false, "record the lines" +
+                  " that run and print the coverage of every file")
+    flags.StringVar(&opts.coverProfile, "coverprofile", "",
+                    "write the coverage profile to `file`")
+    flags.StringVar(&opts.coverReport, "coverreport", "",
+                    "write an annotated coverage report to `file`," +
+                    " HTML if it ends in .html")
+    flags.Float64Var(&opts.coverMin, "covermin", 0, "exit with" +
+                     " status 1 if the coverage of any file is" +
+                     " below `percent`")
+    flags.Parse(os.Args[1:])
+    args := flags.Args()
+    if opts.coverProfile != "" || opts.coverReport != "" ||
+       opts.coverMin > 0 {
+        opts.cover = true
+    }
+
+    blue.Init(args)
+    globals := make(map[string]objects.BlObject, 0)
+    
+    if len(args) > 0 {
+        os.Exit(runFile(args[0], globals, &opts))
+    } else {
+        repl.Init()
+        repl.Run(globals)
+    }
+}
+
+type options struct {
+    profile      string
+    cover        bool
+    coverProfile string
+    coverReport  string
+    coverMin     float64
+}
+
+func runFile(pathname string, globals map[string]objects.BlObject,
+             opts *options) (status int) {
+    f, err := os.Open(pathname)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%s\n", err)
+        return 1
+    }
+    ast, err := parser.ParseFromFile(pathname, f)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%s\n", err)
+        return 1
+    }
+    // Both are also written when the script dies with an error.
+    if opts.profile != "" {
+        prof := blue.StartProfile()
+        defer func() {
+            prof.Stop()
+            if err := prof.WriteFile(opts.profile); err != nil {
+                fmt.Fprintf(os.Stderr, "%s\n", err)
+            }
+        }()
+    }
+    if opts.cover {
+        cov := blue.StartCoverage()
         defer func() {
-            err := recover()
+            cov.Stop()
+            cov.WriteSummary(os.Stderr)
+            err := cov.WriteFiles(opts.coverProfile, opts.coverReport)
             if err != nil {
-                fmt.Println(err)
+                fmt.Fprintf(os.Stderr, "%s\n", err)
+                status = 1
+            }
+            names, percents := cov.Below(opts.coverMin)
+            for i, name := range names {
+                fmt.Fprintf(os.Stderr, "%s: coverage %.1f%% is below" +
+                            " %.1f%%\n", name, percents[i],
+                            opts.coverMin)
+                status = 1
             }
         }()
-        ast := parser.ParseFromFile(pathname, f)
-        runtime := blue.New(pathname, ast)
-        runtime.Run(globals)
-    } else {
-        repl.Init()
-        repl.Run(globals)
     }
-}
\ No newline at end of file
+    defer func() {
+        err := recover()
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%s\n", err)
+            status = 1
+        }
+    }()
+    runtime := blue.New(pathname, ast)
+    runtime.Run(globals)
+
+    return 0
+}
@@ -0,0 +1,540 @@
+package objects
+
+import (
+    "bytes"
+    "encoding/hex"
+    "strings"
+    "unicode/utf8"
+    "github.com/Magnus9/blue/errpkg"
+)
+const BYTES_MAX = 0x00ffffff
+
+/*
+ * Bytes are sequences of raw bytes, kept apart from
+ * strings that hold text. The same object backs both
+ * the immutable bytes type and the mutable bytearray,
+ * the type in the header tells them apart.
+ */
+type BlBytesObject struct {
+    header blHeader
+    value  []byte
+}
+func (bbo *BlBytesObject) BlType() *BlTypeObject {
+    return bbo.header.typeobj
+}
+func (bbo *BlBytesObject) Bytes() []byte {
+    return bbo.value
+}
+var blBytesSequence = BlSequenceMethods{
+    SqItem      : blBytesItem,
+    SqConcat    : blBytesConcat,
+    SqRepeat    : blBytesRepeat,
+    SqSlice     : blBytesSlice,
+    SqSize      : blBytesSize,
+    SqContains  : blBytesContains,
+}
+var blByteArraySequence = BlSequenceMethods{
+    SqItem      : blBytesItem,
+    SqAssItem   : blByteArrayAssItem,
+    SqConcat    : blBytesConcat,
+    SqRepeat    : blBytesRepeat,
+    SqSlice     : blBytesSlice,
+    SqAssSlice  : blByteArrayAssSlice,
+    SqSize      : blBytesSize,
+    SqContains  : blBytesContains,
+}
+var blBytesMethods = []BlGFunctionObject{
+    NewBlGFunction("hex",     bytesHex,     GFUNC_NOARGS ),
+    NewBlGFunction("fromhex", bytesFromHex, GFUNC_VARARGS | GFUNC_CLASS),
+    NewBlGFunction("decode",  bytesDecode,  GFUNC_VARARGS),
+}
+var blByteArrayMethods = []BlGFunctionObject{
+    NewBlGFunction("hex",     bytesHex,         GFUNC_NOARGS ),
+    NewBlGFunction("fromhex", bytesFromHex,     GFUNC_VARARGS | GFUNC_CLASS),
+    NewBlGFunction("decode",  bytesDecode,      GFUNC_VARARGS),
+    NewBlGFunction("append",  byteArrayAppend,  GFUNC_VARARGS),
+    NewBlGFunction("extend",  byteArrayExtend,  GFUNC_VARARGS),
+}
+var BlBytesType BlTypeObject
+var BlByteArrayType BlTypeObject
+
+func NewBlBytes(value []byte) *BlBytesObject {
+    return &BlBytesObject{
+        header: blHeader{&BlBytesType},
+        value : value,
+    }
+}
+
+func NewBlByteArray(value []byte) *BlBytesObject {
+    return &BlBytesObject{
+        header: blHeader{&BlByteArrayType},
+        value : value,
+    }
+}
+
+// Makes a new object of the same type as 'obj'.
+func blBytesNew(obj *BlBytesObject, value []byte) *BlBytesObject {
+    return &BlBytesObject{
+        header: blHeader{obj.BlType()},
+        value : value,
+    }
+}
+
+/*
+ * Returns the bytes of a bytes or bytearray object, and
+ * false for anything else.
+ */
+func blBytesLike(obj BlObject) ([]byte, bool) {
+    bobj, ok := obj.(*BlBytesObject)
+    if !ok {
+        return nil, false
+    }
+    return bobj.value, true
+}
+
+/*
+ * Returns the data to write for a string or bytes-like
+ * object, strings are written as utf-8. Returns nil for
+ * other objects.
+ */
+func blWriteData(obj BlObject) []byte {
+    switch t := obj.(type) {
+        case *BlStringObject:
+            return []byte(t.Value)
+        case *BlBytesObject:
+            return t.value
+    }
+    errpkg.SetErrmsg("expected string or bytes, found '%s'",
+                     obj.BlType().Name)
+    return nil
+}
+
+// Turns an int object into a byte, -1 if out of range.
+func blByteValue(obj BlObject) int {
+    iobj, ok := obj.(*BlIntObject)
+    if !ok {
+        errpkg.SetErrmsg("expected integer for byte, found '%s'",
+                         obj.BlType().Name)
+        return -1
+    }
+    if iobj.IsBig() || iobj.Value < 0 || iobj.Value > 255 {
+        errpkg.SetErrmsg("byte must be in range 0..255")
+        return -1
+    }
+    return int(iobj.Value)
+}
+
+func blBytesItem(obj BlObject, num int) BlObject {
+    bobj := obj.(*BlBytesObject)
+    if num >= len(bobj.value) || num < 0 {
+        errpkg.SetErrmsg("subscript position out of bounds")
+        return nil
+    }
+    return NewBlInt(int64(bobj.value[num]))
+}
+
+func blByteArrayAssItem(obj, value BlObject, num int) int {
+    bobj := obj.(*BlBytesObject)
+    if num >= len(bobj.value) || num < 0 {
+        errpkg.SetErrmsg("subscript position out of bounds")
+        return -1
+    }
+    b := blByteValue(value)
+    if b == -1 {
+        return -1
+    }
+    bobj.value[num] = byte(b)
+    return 0
+}
+
+// The result has the type of the left operand.
+func blBytesConcat(a, b BlObject) BlObject {
+    bobj := a.(*BlBytesObject)
+    value, ok := blBytesLike(b)
+    if !ok {
+        errpkg.SetErrmsg("cannot add '%s' to %s",
+                         b.BlType().Name, bobj.BlType().Name)
+        return nil
+    }
+    buf := make([]byte, 0, len(bobj.value) + len(value))
+    buf = append(buf, bobj.value...)
+    return blBytesNew(bobj, append(buf, value...))
+}
+
+func blBytesRepeat(a, b BlObject) BlObject {
+    iobj, ok := b.(*BlIntObject)
+    if !ok {
+        errpkg.SetErrmsg("cant multiply sequence with" +
+                         " non-integer")
+        return nil
+    }
+    bobj := a.(*BlBytesObject)
+    if iobj.Value < 0 {
+        return blBytesNew(bobj, []byte{})
+    }
+    if iobj.IsBig() || iobj.Value > BYTES_MAX ||
+       len(bobj.value) * int(iobj.Value) > BYTES_MAX {
+        errpkg.SetErrmsg("repeated %s became too large",
+                         bobj.BlType().Name)
+        return nil
+    }
+    return blBytesNew(bobj, bytes.Repeat(bobj.value,
+                                         int(iobj.Value)))
+}
+
+func blBytesSlice(obj BlObject, s, e, step int) BlObject {
+    bobj := obj.(*BlBytesObject)
+    value := make([]byte, blSliceSize(s, e, step))
+    for i := range value {
+        value[i] = bobj.value[s + i * step]
+    }
+    return blBytesNew(bobj, value)
+}
+
+func blByteArrayAssSlice(obj, value BlObject, s, e, step int) int {
+    data, ok := blBytesLike(value)
+    if !ok {
+        errpkg.SetErrmsg("expected bytes for slice assigment" +
+                         " found '%s'", value.BlType().Name)
+        return -1
+    }
+    bobj := obj.(*BlBytesObject)
+    size := blSliceSize(s, e, step)
+    // Like lists, stepped slices keep their size.
+    if step != 1 {
+        if len(data) != size {
+            errpkg.SetErrmsg("cannot assign bytes of size %d to" +
+                             " slice of size %d", len(data), size)
+            return -1
+        }
+        data = append([]byte{}, data...)
+        for i, b := range data {
+            bobj.value[s + i * step] = b
+        }
+        return 0
+    }
+    e = s + size
+    buf := make([]byte, 0, len(bobj.value) - (e - s) + len(data))
+    buf = append(buf, bobj.value[:s]...)
+    buf = append(buf, data...)
+    bobj.value = append(buf, bobj.value[e:]...)
+    return 0
+}
+
+func blBytesSize(obj BlObject) int {
+    return len(obj.(*BlBytesObject).value)
+}
+
+/*
+ * Bytes contain ints in the range of a byte and other
+ * bytes as subsequences.
+ */
+func blBytesContains(obj, item BlObject) int {
+    bobj := obj.(*BlBytesObject)
+    if value, ok := blBytesLike(item); ok {
+        if bytes.Contains(bobj.value, value) {
+            return 1
+        }
+        return 0
+    }
+    b := blByteValue(item)
+    if b == -1 {
+        return -1
+    }
+    if bytes.IndexByte(bobj.value, byte(b)) != -1 {
+        return 1
+    }
+    return 0
+}
+
+/*
+ * Bytes are shown as b"...", where bytes that aren't
+ * printable ascii are escaped.
+ */
+func blBytesString(value []byte) string {
+    var buf bytes.Buffer
+    buf.WriteString("b\"")
+    for _, b := range value {
+        switch b {
+            case '"', '\\':
+                buf.WriteByte('\\')
+                buf.WriteByte(b)
+            case '\n': buf.WriteString("\\n")
+            case '\r': buf.WriteString("\\r")
+            case '\t': buf.WriteString("\\t")
+            default:
+                if b < 0x20 || b >= 0x7f {
+                    buf.WriteString("\\x")
+                    buf.WriteString(hex.EncodeToString([]byte{b}))
+                } else {
+                    buf.WriteByte(b)
+                }
+        }
+    }
+    buf.WriteByte('"')
+    return buf.String()
+}
+
+func blBytesRepr(obj BlObject) *BlStringObject {
+    return NewBlString(blBytesString(obj.(*BlBytesObject).value))
+}
+
+func blByteArrayRepr(obj BlObject) *BlStringObject {
+    value := obj.(*BlBytesObject).value
+    return NewBlString("bytearray(" + blBytesString(value) + ")")
+}
+
+func blBytesGetMember(obj BlObject, name string) BlObject {
+    return genericGetMember(obj.BlType(), name, obj)
+}
+
+func blBytesEvalCond(obj BlObject) bool {
+    return len(obj.(*BlBytesObject).value) > 0
+}
+
+func blBytesCompare(a, b BlObject) int {
+    return bytes.Compare(a.(*BlBytesObject).value,
+                         b.(*BlBytesObject).value)
+}
+
+// Hashed the same way as strings.
+func blBytesHash(obj BlObject) int64 {
+    value := obj.(*BlBytesObject).value
+    if len(value) == 0 {
+        return 0
+    }
+    sum := int64(value[0] << 7)
+    for i := 1; i < len(value); i++ {
+        sum = (1000003 * sum) ^ int64(value[i])
+    }
+    sum ^= int64(len(value))
+    if sum == -1 {
+        sum = -2
+    }
+    return sum
+}
+
+/*
+ * Encodes a string into bytes. utf-8 is the default,
+ * ascii and latin-1 fail on characters they can't hold.
+ */
+func blEncode(str, encoding string) []byte {
+    limit := rune(0)
+    switch strings.ToLower(encoding) {
+        case "utf-8", "utf8":
+            return []byte(str)
+        case "ascii":
+            limit = 0x7f
+        case "latin-1", "latin1", "iso-8859-1":
+            limit = 0xff
+        default:
+            errpkg.SetErrmsg("unknown encoding '%s'", encoding)
+            return nil
+    }
+    value := make([]byte, 0, len(str))
+    pos := 0
+    for _, ch := range str {
+        if ch > limit {
+            errpkg.SetErrmsg("'%s' can't encode character '%c'" +
+                             " at position %d", encoding, ch, pos)
+            return nil
+        }
+        value = append(value, byte(ch))
+        pos++
+    }
+    return value
+}
+
+// The reverse of blEncode.
+func blDecode(value []byte, encoding string) *BlStringObject {
+    switch strings.ToLower(encoding) {
+        case "utf-8", "utf8":
+            for pos := 0; pos < len(value); {
+                ch, size := utf8.DecodeRune(value[pos:])
+                if ch == utf8.RuneError && size <= 1 {
+                    errpkg.SetErrmsg("'%s' can't decode byte" +
+                                     " 0x%02x at position %d",
+                                     encoding, value[pos], pos)
+                    return nil
+                }
+                pos += size
+            }
+            return NewBlString(string(value))
+        case "ascii", "latin-1", "latin1", "iso-8859-1":
+            var buf strings.Builder
+            for pos, b := range value {
+                if b > 0x7f && strings.ToLower(encoding) == "ascii" {
+                    errpkg.SetErrmsg("'%s' can't decode byte" +
+                                     " 0x%02x at position %d",
+                                     encoding, b, pos)
+                    return nil
+                }
+                buf.WriteRune(rune(b))
+            }
+            return NewBlString(buf.String())
+    }
+    errpkg.SetErrmsg("unknown encoding '%s'", encoding)
+    return nil
+}
+
+/*
+ * The constructor takes nothing, a size to fill with
+ * zeroes, a string and its encoding, other bytes or
+ * an iterable of ints.
+ */
+func blBytesValue(args []BlObject) []byte {
+    var arg BlObject
+    var encoding string = "utf-8"
+    if blParseArguments("|os", args, &arg, &encoding) == -1 {
+        return nil
+    }
+    if arg == nil {
+        return []byte{}
+    }
+    switch t := arg.(type) {
+        case *BlStringObject:
+            return blEncode(t.Value, encoding)
+        case *BlBytesObject:
+            value := make([]byte, len(t.value))
+            copy(value, t.value)
+            return value
+        case *BlIntObject:
+            if t.IsBig() || t.Value > BYTES_MAX {
+                errpkg.SetErrmsg("bytes size too large")
+                return nil
+            }
+            if t.Value < 0 {
+                errpkg.SetErrmsg("negative bytes size")
+                return nil
+            }
+            return make([]byte, t.Value)
+    }
+    typeobj := arg.BlType()
+    seq := typeobj.Sequence
+    if seq == nil || seq.SqItem == nil || seq.SqSize == nil {
+        errpkg.SetErrmsg("'%s' object is not iterable",
+                         typeobj.Name)
+        return nil
+    }
+    value := make([]byte, seq.SqSize(arg))
+    for i := range value {
+        b := blByteValue(seq.SqItem(arg, i))
+        if b == -1 {
+            return nil
+        }
+        value[i] = byte(b)
+    }
+    return value
+}
+
+func blBytesInit(obj *BlTypeObject, args ...BlObject) BlObject {
+    value := blBytesValue(args)
+    if value == nil {
+        return nil
+    }
+    return NewBlBytes(value)
+}
+
+func blByteArrayInit(obj *BlTypeObject, args ...BlObject) BlObject {
+    value := blBytesValue(args)
+    if value == nil {
+        return nil
+    }
+    return NewBlByteArray(value)
+}
+
+// Two lower case hex digits per byte.
+func bytesHex(obj BlObject, args ...BlObject) BlObject {
+    return NewBlString(hex.EncodeToString(obj.(*BlBytesObject).value))
+}
+
+/*
+ * The reverse of hex, called on the type. Takes two hex
+ * digits per byte in either case.
+ */
+func bytesFromHex(obj BlObject, args ...BlObject) BlObject {
+    var str string
+    if blParseArguments("s", args, &str) == -1 {
+        return nil
+    }
+    for pos, ch := range []rune(str) {
+        if !strings.ContainsRune("0123456789abcdefABCDEF", ch) {
+            errpkg.SetErrmsg("non-hex digit '%c' at position %d",
+                             ch, pos)
+            return nil
+        }
+    }
+    if len(str) % 2 != 0 {
+        errpkg.SetErrmsg("hex string has an odd length")
+        return nil
+    }
+    value, _ := hex.DecodeString(str)
+    if obj == &BlByteArrayType {
+        return NewBlByteArray(value)
+    }
+    return NewBlBytes(value)
+}
+
+func bytesDecode(obj BlObject, args ...BlObject) BlObject {
+    var encoding string = "utf-8"
+    if blParseArguments("|s", args, &encoding) == -1 {
+        return nil
+    }
+    sobj := blDecode(obj.(*BlBytesObject).value, encoding)
+    if sobj == nil {
+        return nil
+    }
+    return sobj
+}
+
+func byteArrayAppend(obj BlObject, args ...BlObject) BlObject {
+    var arg BlObject
+    if blParseArguments("o", args, &arg) == -1 {
+        return nil
+    }
+    b := blByteValue(arg)
+    if b == -1 {
+        return nil
+    }
+    bobj := obj.(*BlBytesObject)
+    bobj.value = append(bobj.value, byte(b))
+    return BlNil
+}
+
+func byteArrayExtend(obj BlObject, args ...BlObject) BlObject {
+    value := blBytesValue(args)
+    if value == nil {
+        return nil
+    }
+    bobj := obj.(*BlBytesObject)
+    bobj.value = append(bobj.value, value...)
+    return BlNil
+}
+
+func blInitBytes() {
+    BlBytesType = BlTypeObject{
+        header   : blHeader{&BlTypeType},
+        Name     : "bytes",
+        Repr     : blBytesRepr,
+        GetMember: blBytesGetMember,
+        EvalCond : blBytesEvalCond,
+        Compare  : blBytesCompare,
+        hash     : blBytesHash,
+        Init     : blBytesInit,
+        Sequence : &blBytesSequence,
+        methods  : blBytesMethods,
+    }
+    blTypeFinish(&BlBytesType)
+    BlByteArrayType = BlTypeObject{
+        header   : blHeader{&BlTypeType},
+        Name     : "bytearray",
+        Repr     : blByteArrayRepr,
+        GetMember: blBytesGetMember,
+        EvalCond : blBytesEvalCond,
+        Compare  : blBytesCompare,
+        Init     : blByteArrayInit,
+        Sequence : &blByteArraySequence,
+        methods  : blByteArrayMethods,
+    }
+    blTypeFinish(&BlByteArrayType)
+}
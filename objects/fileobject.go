@@ -4,6 +4,7 @@ package objects
 import (
     "fmt"
     "os"
+    "strings"
     "github.com/Magnus9/blue/errpkg"
 )
 type BlFileObject struct {
@@ -11,6 +12,8 @@ type BlFileObject struct {
     f      *os.File
     mode   string
     open   bool
+    // Reads return bytes instead of strings.
+    binary bool
 }
 func (bfo *BlFileObject) BlType() *BlTypeObject {
     return bfo.header.typeobj
@@ -23,6 +26,7 @@ func NewBlFile(f *os.File, mode string) *BlFileObject {
         f     : f,
         mode  : mode,
         open  : true,
+        binary: strings.IndexByte(mode, 'b') != -1,
     }
 }
 
@@ -55,6 +59,7 @@ func setModeBits(mode string) int {
                 wfound = true
             case 'a': flag |= os.O_APPEND
             case 't': flag |= os.O_TRUNC
+            case 'b': // Handled by NewBlFile.
             default:
                 errpkg.SetErrmsg("unrecognized file mode char" +
                                  " '%c'", ch)
@@ -91,6 +96,14 @@ func blFileInit(obj *BlTypeObject,
     return NewBlFile(f, mode)
 }
 
+// The data read as bytes or a string, by the file mode.
+func blFileData(fobj *BlFileObject, data []byte) BlObject {
+    if fobj.binary {
+        return NewBlBytes(data)
+    }
+    return NewBlString(string(data))
+}
+
 func blFileRead(self BlObject, args ...BlObject) BlObject {
     var size int64
     if blParseArguments("i", args, &size) == -1 {
@@ -104,10 +117,10 @@ func blFileRead(self BlObject, args ...BlObject) BlObject {
             errpkg.SetErrmsg(err.Error())
             return nil
         } else {
-            return NewBlString("")
+            return blFileData(fobj, []byte{})
         }
     }
-    return NewBlString(string(data))
+    return blFileData(fobj, data[:num])
 }
 
 func blFileReadAll(self BlObject, args ...BlObject) BlObject {
@@ -119,7 +132,7 @@ func blFileReadAll(self BlObject, args ...BlObject) BlObject {
     }
     siz := finfo.Size()
     if siz == 0 {
-        return NewBlString("")
+        return blFileData(fobj, []byte{})
     }
     data := make([]byte, siz)
     num, err := fobj.f.Read(data)
@@ -128,19 +141,23 @@ func blFileReadAll(self BlObject, args ...BlObject) BlObject {
             errpkg.SetErrmsg(err.Error())
             return nil
         } else {
-            return NewBlString("")
+            return blFileData(fobj, []byte{})
         }
     }
-    return NewBlString(string(data))
+    return blFileData(fobj, data[:num])
 }
 
 func blFileWrite(self BlObject, args ...BlObject) BlObject {
-    var buf string
-    if blParseArguments("s", args, &buf) == -1 {
+    var arg BlObject
+    if blParseArguments("o", args, &arg) == -1 {
+        return nil
+    }
+    buf := blWriteData(arg)
+    if buf == nil {
         return nil
     }
     fobj := self.(*BlFileObject)
-    num, err := fobj.f.WriteString(buf)
+    num, err := fobj.f.Write(buf)
     if err != nil {
         errpkg.SetErrmsg(err.Error())
         return nil
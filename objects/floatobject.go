@@ -1,13 +1,17 @@
 
 /*
- * Pretty primitive float object. For now it
- * can only present integral and fraction bits.
+ * Floats are IEEE doubles. They print as the shortest
+ * string that reads back as the same float, in
+ * scientific notation when they are very large or very
+ * small. nan is unordered, it compares unequal to
+ * everything including itself.
  */
 package objects
 
 import (
-    "fmt"
     "math"
+    "strconv"
+    "strings"
     "github.com/Magnus9/blue/errpkg"
 )
 type BlFloatObject struct {
@@ -17,14 +21,19 @@ type BlFloatObject struct {
 func (bfo *BlFloatObject) BlType() *BlTypeObject {
     return bfo.header.typeobj
 }
+func (bfo *BlFloatObject) Float() float64 {
+    return bfo.value
+}
 var blFloatNumbers = BlNumberMethods{
-    NumNeg   : blFloatNeg,
-    NumAdd   : blFloatAdd,
-    NumSub   : blFloatSub,
-    NumMul   : blFloatMul,
-    NumDiv   : blFloatDiv,
-    NumMod   : blFloatMod,
-    NumCoerce: blFloatCoerce,
+    NumNeg     : blFloatNeg,
+    NumAdd     : blFloatAdd,
+    NumSub     : blFloatSub,
+    NumMul     : blFloatMul,
+    NumDiv     : blFloatDiv,
+    NumMod     : blFloatMod,
+    NumFloorDiv: blFloatFloorDiv,
+    NumPow     : blFloatPow,
+    NumCoerce  : blFloatCoerce,
 }
 var BlFloatType BlTypeObject
 
@@ -75,7 +84,38 @@ func blFloatMod(a, b BlObject) BlObject {
         errpkg.SetErrmsg("float modulo by zero")
         return nil
     }
-    return NewBlFloat(math.Mod(aFobj.value, bFobj.value))
+    // The sign of the divisor, like ints, zero included.
+    rem := math.Mod(aFobj.value, bFobj.value)
+    if rem == 0 {
+        rem = math.Copysign(0, bFobj.value)
+    } else if (rem < 0) != (bFobj.value < 0) {
+        rem += bFobj.value
+    }
+    return NewBlFloat(rem)
+}
+
+func blFloatFloorDiv(a, b BlObject) BlObject {
+    aFobj := a.(*BlFloatObject)
+    bFobj := b.(*BlFloatObject)
+    if bFobj.value == 0.0 {
+        errpkg.SetErrmsg("float division by zero")
+        return nil
+    }
+    return NewBlFloat(math.Floor(aFobj.value / bFobj.value))
+}
+
+func blFloatPow(a, b BlObject) BlObject {
+    x := a.(*BlFloatObject).value
+    y := b.(*BlFloatObject).value
+    if x == 0.0 && y < 0.0 {
+        errpkg.SetErrmsg("zero to a negative power")
+        return nil
+    }
+    if x < 0.0 && y != math.Trunc(y) && !math.IsInf(y, 0) {
+        errpkg.SetErrmsg("negative number to a fractional power")
+        return nil
+    }
+    return NewBlFloat(math.Pow(x, y))
 }
 
 func blFloatCoerce(a, b *BlObject) int {
@@ -83,15 +123,41 @@ func blFloatCoerce(a, b *BlObject) int {
     if !ok {
         return -1
     }
-    obj := NewBlFloat(float64(t.Value))
+    obj := NewBlFloat(t.Float())
     *b = obj
 
     return 0
 }
 
+/*
+ * The shortest string that reads back as 'value'. The
+ * exponent is written out below 1e-4 and from 1e16 on,
+ * where the digits would mostly be zeros.
+ */
+func blFormatFloat(value float64) string {
+    switch {
+        case math.IsNaN(value):
+            return "nan"
+        case math.IsInf(value, 1):
+            return "inf"
+        case math.IsInf(value, -1):
+            return "-inf"
+    }
+    str := strconv.FormatFloat(value, 'e', -1, 64)
+    exp, _ := strconv.Atoi(str[strings.IndexByte(str, 'e') + 1:])
+    if exp < -4 || exp >= 16 {
+        return str
+    }
+    str = strconv.FormatFloat(value, 'f', -1, 64)
+    if !strings.Contains(str, ".") {
+        str += ".0"
+    }
+    return str
+}
+
 func blFloatRepr(obj BlObject) *BlStringObject {
     fobj := obj.(*BlFloatObject)
-    return NewBlString(fmt.Sprintf("%f", fobj.value))
+    return NewBlString(blFormatFloat(fobj.value))
 }
 
 func blFloatEvalCond(obj BlObject) bool {
@@ -106,6 +172,8 @@ func blFloatCompare(a, b BlObject) int {
     aFobj := a.(*BlFloatObject)
     bFobj := b.(*BlFloatObject)
     switch {
+    case math.IsNaN(aFobj.value) || math.IsNaN(bFobj.value):
+        return CMP_UNORDERED
     case aFobj.value < bFobj.value:
         return -1
     case aFobj.value > bFobj.value:
@@ -128,9 +196,18 @@ func blFloatInit(obj *BlTypeObject,
         case *BlFloatObject:
             return t
         case *BlIntObject:
-            return NewBlFloat(float64(t.Value))
+            return NewBlFloat(t.Float())
+        case *BlStringObject:
+            // Also takes "inf", "-inf" and "nan".
+            value, err := strconv.ParseFloat(strings.TrimSpace(t.Value),
+                                             64)
+            if err != nil && !math.IsInf(value, 0) {
+                errpkg.SetErrmsg("invalid float literal '%s'", t.Value)
+                return nil
+            }
+            return NewBlFloat(value)
     }
-    errpkg.SetErrmsg("expected number")
+    errpkg.SetErrmsg("expected number or string")
     return nil
 }
 
@@ -23,6 +23,16 @@ func NewBlFrame(prev *BlFrame,
         Name    : name,
     }
 }
+/*
+ * Called with every node a frame moves to, when tools
+ * like the profiler need to follow execution. Nil the
+ * rest of the time.
+ */
+var BlTraceNode func(frame *BlFrame, node *interm.Node)
+
 func (bf *BlFrame) SetNode(node *interm.Node) {
     bf.Node = node
+    if BlTraceNode != nil {
+        BlTraceNode(bf, node)
+    }
 }
\ No newline at end of file
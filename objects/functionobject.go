@@ -9,6 +9,8 @@ type BlFunctionObject struct {
     header    blHeader
     Path      string
     Name      string
+    // Line of the 'def'.
+    LineNum   int
     Globals   map[string]BlObject
     Params    []string
     ParamLen  int
@@ -20,13 +22,15 @@ func (bfo *BlFunctionObject) BlType() *BlTypeObject {
 }
 var BlFunctionType BlTypeObject
 
-func NewBlFunction(path, name string, globals map[string]BlObject,
+func NewBlFunction(path, name string, lineNum int,
+                   globals map[string]BlObject,
                    params []string, paramLen int,
                    block *interm.Node, starParam bool) BlObject {
     bfo := &BlFunctionObject{
         header   : blHeader{&BlFunctionType},
         Path     : path,
         Name     : name,
+        LineNum  : lineNum,
         Globals  : globals,
         Params   : params,
         ParamLen : paramLen,
@@ -13,8 +13,14 @@ import (
     "fmt"
 )
 const (
-    GFUNC_NOARGS  = 1
-    GFUNC_VARARGS = 2
+    GFUNC_NOARGS   = 1
+    GFUNC_VARARGS  = 2
+    // Keyword arguments are passed as a map after the
+    // other arguments, see BlParseKeywords.
+    GFUNC_KEYWORDS = 4
+    // Class functions are bound to the type, both when
+    // looked up on the type and on its instances.
+    GFUNC_CLASS    = 8
 )
 type gfunction func(BlObject, ...BlObject) BlObject
 type BlGFunctionObject struct {
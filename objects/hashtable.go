@@ -0,0 +1,201 @@
+package objects
+
+/*
+ * The hash table behind maps and sets. Every bucket is
+ * a list of indexes into 'entries'. Keys that hash the
+ * same are told apart with BlCompare. The entries are
+ * kept in the order they were inserted.
+ */
+type hashEntry struct {
+    key  BlObject
+    val  BlObject
+    hash int64
+}
+
+type hashTable struct {
+    buckets [][]int
+    // Deleted entries have a nil key until they are
+    // compacted away.
+    entries []hashEntry
+    size    int
+}
+
+// The number of buckets of an empty table, a power of 2.
+const hashMinBuckets = 8
+
+func newHashTable() hashTable {
+    return hashTable{
+        buckets: make([][]int, hashMinBuckets),
+    }
+}
+
+func (ht *hashTable) bucket(hash int64) int {
+    return int(uint64(hash) & uint64(len(ht.buckets) - 1))
+}
+
+func hashKeysEqual(a, b BlObject) bool {
+    return a == b || BlCompare(a, b) == 0
+}
+
+/*
+ * Find the entry of 'key', which hashes to 'hash'.
+ * Returns the index of the entry, or -1.
+ */
+func (ht *hashTable) lookup(key BlObject, hash int64) int {
+    for _, i := range ht.buckets[ht.bucket(hash)] {
+        entry := &ht.entries[i]
+        if entry.hash == hash && hashKeysEqual(entry.key, key) {
+            return i
+        }
+    }
+    return -1
+}
+
+func (ht *hashTable) insert(key, value BlObject, hash int64) {
+    if ht.size >= len(ht.buckets) {
+        ht.grow()
+    }
+    b := ht.bucket(hash)
+    ht.buckets[b] = append(ht.buckets[b], len(ht.entries))
+    ht.entries = append(ht.entries, hashEntry{key, value, hash})
+    ht.size++
+}
+
+// Double the buckets, keeping the load factor below 1.
+func (ht *hashTable) grow() {
+    ht.buckets = make([][]int, len(ht.buckets) * 2)
+    ht.rehash()
+}
+
+func (ht *hashTable) rehash() {
+    for i := range ht.entries {
+        b := ht.bucket(ht.entries[i].hash)
+        ht.buckets[b] = append(ht.buckets[b], i)
+    }
+}
+
+/*
+ * Remove the entry at 'i'. The entry is left behind
+ * with a nil key, and the entries are compacted once
+ * half of them are deleted.
+ */
+func (ht *hashTable) remove(i int) {
+    entry := &ht.entries[i]
+    b := ht.bucket(entry.hash)
+    bucket := ht.buckets[b]
+    for j, k := range bucket {
+        if k == i {
+            ht.buckets[b] = append(bucket[:j], bucket[j + 1:]...)
+            break
+        }
+    }
+    *entry = hashEntry{}
+    ht.size--
+    if ht.size < len(ht.entries) / 2 {
+        ht.compact()
+    }
+}
+
+func (ht *hashTable) compact() {
+    entries := make([]hashEntry, 0, ht.size)
+    for _, entry := range ht.entries {
+        if entry.key != nil {
+            entries = append(entries, entry)
+        }
+    }
+    ht.entries = entries
+    ht.buckets = make([][]int, len(ht.buckets))
+    ht.rehash()
+}
+
+/*
+ * Get the value of 'key'. Returns nil without setting
+ * an error if the key is missing, and nil with an error
+ * if it isn't hashable, which 'ok' tells apart.
+ */
+func (ht *hashTable) get(key BlObject) (BlObject, bool) {
+    hash := blObjectHash(key)
+    if hash == -1 {
+        return nil, false
+    }
+    if i := ht.lookup(key, hash); i != -1 {
+        return ht.entries[i].val, true
+    }
+    return nil, true
+}
+
+// Returns 1 if 'key' is in the table, 0 if not and -1 on error.
+func (ht *hashTable) contains(key BlObject) int {
+    val, ok := ht.get(key)
+    if !ok {
+        return -1
+    }
+    if val == nil {
+        return 0
+    }
+    return 1
+}
+
+// Insert or update 'key'. Returns -1 if it isn't hashable.
+func (ht *hashTable) set(key, value BlObject) int {
+    hash := blObjectHash(key)
+    if hash == -1 {
+        return -1
+    }
+    if i := ht.lookup(key, hash); i != -1 {
+        ht.entries[i].val = value
+    } else {
+        ht.insert(key, value, hash)
+    }
+    return 0
+}
+
+// Remove 'key' and return its value, nil if it is missing.
+func (ht *hashTable) delete(key BlObject) (BlObject, bool) {
+    hash := blObjectHash(key)
+    if hash == -1 {
+        return nil, false
+    }
+    i := ht.lookup(key, hash)
+    if i == -1 {
+        return nil, true
+    }
+    val := ht.entries[i].val
+    ht.remove(i)
+    return val, true
+}
+
+// Insert every entry of 'other'.
+func (ht *hashTable) merge(other *hashTable) {
+    for _, entry := range other.entries {
+        if entry.key == nil {
+            continue
+        }
+        if i := ht.lookup(entry.key, entry.hash); i != -1 {
+            ht.entries[i].val = entry.val
+        } else {
+            ht.insert(entry.key, entry.val, entry.hash)
+        }
+    }
+}
+
+func (ht *hashTable) clear() {
+    *ht = newHashTable()
+}
+
+// Call 'fn' with every entry, in insertion order.
+func (ht *hashTable) each(fn func(key, val BlObject)) {
+    for _, entry := range ht.entries {
+        if entry.key != nil {
+            fn(entry.key, entry.val)
+        }
+    }
+}
+
+// The key of the n'th entry in insertion order.
+func (ht *hashTable) nth(n int) BlObject {
+    if len(ht.entries) != ht.size {
+        ht.compact()
+    }
+    return ht.entries[n].key
+}
@@ -4,29 +4,60 @@ package objects
 import (
     "fmt"
     "math"
+    "math/big"
+    "strings"
     "github.com/Magnus9/blue/errpkg"
 )
+// The most bits a shift can move an int by.
+const INT_MAX_SHIFT = 0x00ffffff
+
+/*
+ * Ints are int64s until an operation overflows, then
+ * the result is kept in a big.Int instead. Value is set
+ * to the nearest int64 in that case, so code that only
+ * looks at Value sees a number out of every bound.
+ */
 type BlIntObject struct {
     header blHeader
     Value  int64
+    bval   *big.Int
 }
 func (bio *BlIntObject) BlType() *BlTypeObject {
     return bio.header.typeobj
 }
+func (bio *BlIntObject) IsBig() bool {
+    return bio.bval != nil
+}
+// The value as a big.Int, which must not be modified.
+func (bio *BlIntObject) Big() *big.Int {
+    if bio.bval != nil {
+        return bio.bval
+    }
+    return big.NewInt(bio.Value)
+}
+func (bio *BlIntObject) Float() float64 {
+    if bio.bval != nil {
+        f, _ := new(big.Float).SetInt(bio.bval).Float64()
+        return f
+    }
+    return float64(bio.Value)
+}
 
 var blIntNumbers = BlNumberMethods{
-    NumNeg   : blIntNeg,
-    NumCompl : blIntCompl,
-    NumOr    : blIntOr,
-    NumAnd   : blIntAnd,
-    NumXor   : blIntXor,
-    NumLshift: blIntLshift,
-    NumRshift: blIntRshift,
-    NumAdd   : blIntAdd,
-    NumSub   : blIntSub,
-    NumMul   : blIntMul,
-    NumDiv   : blIntDiv,
-    NumMod   : blIntMod,
+    NumNeg     : blIntNeg,
+    NumCompl   : blIntCompl,
+    NumOr      : blIntOr,
+    NumAnd     : blIntAnd,
+    NumXor     : blIntXor,
+    NumLshift  : blIntLshift,
+    NumRshift  : blIntRshift,
+    NumAdd     : blIntAdd,
+    NumSub     : blIntSub,
+    NumMul     : blIntMul,
+    NumDiv     : blIntDiv,
+    NumMod     : blIntMod,
+    NumFloorDiv: blIntFloorDiv,
+    NumPow     : blIntPow,
 }
 var blIntSequence = BlSequenceMethods{
     SqItem      : blIntItem,
@@ -41,86 +72,270 @@ func NewBlInt(value int64) *BlIntObject {
     }
 }
 
+/*
+ * Make an int out of a big.Int. It is only kept as a
+ * big.Int if it doesn't fit in an int64.
+ */
+func NewBlBigInt(value *big.Int) *BlIntObject {
+    if value.IsInt64() {
+        return NewBlInt(value.Int64())
+    }
+    iobj := NewBlInt(math.MaxInt64)
+    if value.Sign() < 0 {
+        iobj.Value = math.MinInt64
+    }
+    iobj.bval = value
+    return iobj
+}
+
+/*
+ * Parse an int of any size, in decimal or in hex with
+ * a '0x' prefix. A sign is allowed in front.
+ */
+func BlIntFromString(str string) *BlIntObject {
+    digits, base := str, 10
+    if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+        digits = digits[1:]
+    }
+    if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+        digits, base = digits[2:], 16
+    }
+    value, ok := new(big.Int).SetString(digits, base)
+    if !ok || digits[0] == '-' || digits[0] == '+' {
+        errpkg.SetErrmsg("invalid int literal '%s'", str)
+        return nil
+    }
+    if str[0] == '-' {
+        value.Neg(value)
+    }
+    return NewBlBigInt(value)
+}
+
 func blIntNeg(obj BlObject) BlObject {
     iobj := obj.(*BlIntObject)
-    return NewBlInt(-iobj.Value)
+    if iobj.bval == nil && iobj.Value != math.MinInt64 {
+        return NewBlInt(-iobj.Value)
+    }
+    return NewBlBigInt(new(big.Int).Neg(iobj.Big()))
 }
 
 func blIntCompl(obj BlObject) BlObject {
     iobj := obj.(*BlIntObject)
-    return NewBlInt(^iobj.Value)
+    if iobj.bval == nil {
+        return NewBlInt(^iobj.Value)
+    }
+    return NewBlBigInt(new(big.Int).Not(iobj.bval))
 }
 
 func blIntOr(a, b BlObject) BlObject {
     aIobj := a.(*BlIntObject)
     bIobj := b.(*BlIntObject)
-    return NewBlInt(aIobj.Value | bIobj.Value)
+    if aIobj.bval == nil && bIobj.bval == nil {
+        return NewBlInt(aIobj.Value | bIobj.Value)
+    }
+    return NewBlBigInt(new(big.Int).Or(aIobj.Big(), bIobj.Big()))
 }
 
 func blIntAnd(a, b BlObject) BlObject {
     aIobj := a.(*BlIntObject)
     bIobj := b.(*BlIntObject)
-    return NewBlInt(aIobj.Value & bIobj.Value)
+    if aIobj.bval == nil && bIobj.bval == nil {
+        return NewBlInt(aIobj.Value & bIobj.Value)
+    }
+    return NewBlBigInt(new(big.Int).And(aIobj.Big(), bIobj.Big()))
 }
 
 func blIntXor(a, b BlObject) BlObject {
     aIobj := a.(*BlIntObject)
     bIobj := b.(*BlIntObject)
-    return NewBlInt(aIobj.Value ^ bIobj.Value)
+    if aIobj.bval == nil && bIobj.bval == nil {
+        return NewBlInt(aIobj.Value ^ bIobj.Value)
+    }
+    return NewBlBigInt(new(big.Int).Xor(aIobj.Big(), bIobj.Big()))
+}
+
+// Returns -1 if the shift count is negative or too large.
+func blIntShiftCount(obj *BlIntObject) int {
+    if obj.Value < 0 {
+        errpkg.SetErrmsg("negative shift count")
+        return -1
+    }
+    if obj.Value > INT_MAX_SHIFT {
+        errpkg.SetErrmsg("shift count too large")
+        return -1
+    }
+    return int(obj.Value)
 }
 
 func blIntLshift(a, b BlObject) BlObject {
     aIobj := a.(*BlIntObject)
-    bIobj := b.(*BlIntObject)
-    return NewBlInt(aIobj.Value << uint(bIobj.Value))
+    count := blIntShiftCount(b.(*BlIntObject))
+    if count == -1 {
+        return nil
+    }
+    if aIobj.bval == nil && count < 64 {
+        value := aIobj.Value << uint(count)
+        if value >> uint(count) == aIobj.Value {
+            return NewBlInt(value)
+        }
+    }
+    return NewBlBigInt(new(big.Int).Lsh(aIobj.Big(), uint(count)))
 }
 
 func blIntRshift(a, b BlObject) BlObject {
     aIobj := a.(*BlIntObject)
-    bIobj := b.(*BlIntObject)
-    return NewBlInt(aIobj.Value >> uint(bIobj.Value))
+    count := blIntShiftCount(b.(*BlIntObject))
+    if count == -1 {
+        return nil
+    }
+    if aIobj.bval == nil {
+        return NewBlInt(aIobj.Value >> uint(count))
+    }
+    return NewBlBigInt(new(big.Int).Rsh(aIobj.bval, uint(count)))
 }
 
 func blIntAdd(a, b BlObject) BlObject {
     aIobj := a.(*BlIntObject)
     bIobj := b.(*BlIntObject)
-    return NewBlInt(aIobj.Value + bIobj.Value)
+    if aIobj.bval == nil && bIobj.bval == nil {
+        // The sum overflowed if it moved the wrong way.
+        sum := aIobj.Value + bIobj.Value
+        if (sum > aIobj.Value) == (bIobj.Value > 0) {
+            return NewBlInt(sum)
+        }
+    }
+    return NewBlBigInt(new(big.Int).Add(aIobj.Big(), bIobj.Big()))
 }
 
 func blIntSub(a, b BlObject) BlObject {
     aIobj := a.(*BlIntObject)
     bIobj := b.(*BlIntObject)
-    return NewBlInt(aIobj.Value - bIobj.Value)
+    if aIobj.bval == nil && bIobj.bval == nil {
+        diff := aIobj.Value - bIobj.Value
+        if (diff < aIobj.Value) == (bIobj.Value > 0) {
+            return NewBlInt(diff)
+        }
+    }
+    return NewBlBigInt(new(big.Int).Sub(aIobj.Big(), bIobj.Big()))
 }
 
 func blIntMul(a, b BlObject) BlObject {
     aIobj := a.(*BlIntObject)
     bIobj := b.(*BlIntObject)
-    return NewBlInt(aIobj.Value * bIobj.Value)
+    if aIobj.bval == nil && bIobj.bval == nil {
+        x, y := aIobj.Value, bIobj.Value
+        if x == 0 || y == 0 {
+            return NewBlInt(0)
+        }
+        // Dividing the product back finds an overflow,
+        // except for MinInt64 * -1 which divides back.
+        prod := x * y
+        if prod / y == x && !(x == math.MinInt64 && y == -1) {
+            return NewBlInt(prod)
+        }
+    }
+    return NewBlBigInt(new(big.Int).Mul(aIobj.Big(), bIobj.Big()))
 }
 
+/*
+ * Division truncates toward zero, for small and big ints
+ * alike. Modulo takes the sign of the divisor instead, to
+ * go with floor division.
+ */
 func blIntDiv(a, b BlObject) BlObject {
     aIobj := a.(*BlIntObject)
     bIobj := b.(*BlIntObject)
-    if bIobj.Value == 0 {
+    if bIobj.bval == nil && bIobj.Value == 0 {
         errpkg.SetErrmsg("int division by zero")
         return nil
     }
-    return NewBlInt(aIobj.Value / bIobj.Value)
+    if aIobj.bval == nil && bIobj.bval == nil &&
+       !(aIobj.Value == math.MinInt64 && bIobj.Value == -1) {
+        return NewBlInt(aIobj.Value / bIobj.Value)
+    }
+    return NewBlBigInt(new(big.Int).Quo(aIobj.Big(), bIobj.Big()))
 }
 
 func blIntMod(a, b BlObject) BlObject {
     aIobj := a.(*BlIntObject)
     bIobj := b.(*BlIntObject)
-    if bIobj.Value == 0 {
+    if bIobj.bval == nil && bIobj.Value == 0 {
         errpkg.SetErrmsg("int modulo by zero")
         return nil
     }
-    return NewBlInt(aIobj.Value % bIobj.Value)
+    if aIobj.bval == nil && bIobj.bval == nil {
+        rem := aIobj.Value % bIobj.Value
+        if rem != 0 && (rem < 0) != (bIobj.Value < 0) {
+            rem += bIobj.Value
+        }
+        return NewBlInt(rem)
+    }
+    rem := new(big.Int).Rem(aIobj.Big(), bIobj.Big())
+    if rem.Sign() != 0 && rem.Sign() != bIobj.Big().Sign() {
+        rem.Add(rem, bIobj.Big())
+    }
+    return NewBlBigInt(rem)
+}
+
+// Floor division rounds toward negative infinity instead.
+func blIntFloorDiv(a, b BlObject) BlObject {
+    aIobj := a.(*BlIntObject)
+    bIobj := b.(*BlIntObject)
+    if bIobj.bval == nil && bIobj.Value == 0 {
+        errpkg.SetErrmsg("int division by zero")
+        return nil
+    }
+    if aIobj.bval == nil && bIobj.bval == nil &&
+       !(aIobj.Value == math.MinInt64 && bIobj.Value == -1) {
+        x, y := aIobj.Value, bIobj.Value
+        quo := x / y
+        if x % y != 0 && (x < 0) != (y < 0) {
+            quo--
+        }
+        return NewBlInt(quo)
+    }
+    quo, rem := new(big.Int).QuoRem(aIobj.Big(), bIobj.Big(),
+                                    new(big.Int))
+    if rem.Sign() != 0 && rem.Sign() != bIobj.Big().Sign() {
+        quo.Sub(quo, big.NewInt(1))
+    }
+    return NewBlBigInt(quo)
+}
+
+/*
+ * Powers with a negative exponent are floats, the rest
+ * are ints that grow as big as they need to, up to
+ * about as many bits as a shift can make.
+ */
+func blIntPow(a, b BlObject) BlObject {
+    aIobj := a.(*BlIntObject)
+    bIobj := b.(*BlIntObject)
+    if bIobj.Big().Sign() < 0 {
+        if aIobj.bval == nil && aIobj.Value == 0 {
+            errpkg.SetErrmsg("zero to a negative power")
+            return nil
+        }
+        return NewBlFloat(math.Pow(aIobj.Float(), bIobj.Float()))
+    }
+    base := aIobj.Big()
+    if base.CmpAbs(big.NewInt(1)) > 0 &&
+       (bIobj.bval != nil || bIobj.Value > INT_MAX_SHIFT ||
+        int64(base.BitLen() - 1) * bIobj.Value > INT_MAX_SHIFT) {
+        errpkg.SetErrmsg("power result too large")
+        return nil
+    }
+    return NewBlBigInt(new(big.Int).Exp(base, bIobj.Big(), nil))
 }
 
 func blIntItem(obj BlObject, num int) BlObject {
     iobj := obj.(*BlIntObject)
+    if iobj.bval != nil {
+        if num < 0 {
+            errpkg.SetErrmsg("subscript position out of bounds")
+            return nil
+        }
+        return NewBlInt(int64(iobj.bval.Bit(num)))
+    }
     if num > 63  || num < 0 {
         errpkg.SetErrmsg("subscript position out of bounds")
         return nil
@@ -131,7 +346,7 @@ func blIntItem(obj BlObject, num int) BlObject {
 func blIntAssItem(obj BlObject, value BlObject,
                      num int) int {
     iobj := obj.(*BlIntObject)
-    if num > 63 || num < 0 {
+    if num > 63 && iobj.bval == nil || num < 0 {
         errpkg.SetErrmsg("subscript position out of bounds")
         return -1
     }
@@ -144,6 +359,11 @@ func blIntAssItem(obj BlObject, value BlObject,
         errpkg.SetErrmsg("value must be either 1 or 0")
         return -1
     }
+    if iobj.bval != nil {
+        bits := new(big.Int).SetBit(iobj.bval, num, uint(t.Value))
+        *iobj = *NewBlBigInt(bits)
+        return 0
+    }
     bitValue := int64(math.Pow(2, float64(num)))
     if t.Value == 1 {
         iobj.Value |= bitValue
@@ -155,6 +375,9 @@ func blIntAssItem(obj BlObject, value BlObject,
 
 func blIntRepr(obj BlObject) *BlStringObject {
     iobj := obj.(*BlIntObject)
+    if iobj.bval != nil {
+        return NewBlString(iobj.bval.String())
+    }
     str := fmt.Sprintf("%d", iobj.Value)
 
     return NewBlString(str)
@@ -171,6 +394,9 @@ func blIntEvalCond(obj BlObject) bool {
 func blIntCompare(a, b BlObject) int {
     aIobj := a.(*BlIntObject)
     bIobj := b.(*BlIntObject)
+    if aIobj.bval != nil || bIobj.bval != nil {
+        return aIobj.Big().Cmp(bIobj.Big())
+    }
     switch {
     case aIobj.Value < bIobj.Value:
         return -1
@@ -181,8 +407,37 @@ func blIntCompare(a, b BlObject) int {
     }
 }
 
+// Big ints are hashed modulo the prime 2^61 - 1.
+var blIntHashModulus = big.NewInt(1 << 61 - 1)
+
 func blIntHash(obj BlObject) int64 {
-    return obj.(*BlIntObject).Value
+    iobj := obj.(*BlIntObject)
+    hash := iobj.Value
+    if iobj.bval != nil {
+        hash = new(big.Int).Mod(iobj.bval, blIntHashModulus).Int64()
+    }
+    // -1 tells that an object is not hashable.
+    if hash == -1 {
+        return -2
+    }
+    return hash
+}
+
+/*
+ * The int part of a float, truncated toward zero. Floats
+ * too big for an int64 make a big int.
+ */
+func BlIntFromFloat(value float64) *BlIntObject {
+    if math.IsInf(value, 0) || math.IsNaN(value) {
+        errpkg.SetErrmsg("cannot convert %s to int",
+                         blFormatFloat(value))
+        return nil
+    }
+    if value >= -(1 << 63) && value < 1 << 63 {
+        return NewBlInt(int64(value))
+    }
+    bval, _ := big.NewFloat(value).Int(nil)
+    return NewBlBigInt(bval)
 }
 
 func blIntInit(obj *BlTypeObject,
@@ -198,9 +453,17 @@ func blIntInit(obj *BlTypeObject,
         case *BlIntObject:
             return t
         case *BlFloatObject:
-            return NewBlInt(int64(t.value))
+            if iobj := BlIntFromFloat(t.value); iobj != nil {
+                return iobj
+            }
+            return nil
+        case *BlStringObject:
+            if iobj := BlIntFromString(t.Value); iobj != nil {
+                return iobj
+            }
+            return nil
     }
-    errpkg.SetErrmsg("expected number")
+    errpkg.SetErrmsg("expected number or string")
     return nil
 }
 
@@ -3,6 +3,7 @@ package objects
 
 import (
     "bytes"
+    "sort"
     "github.com/Magnus9/blue/errpkg"
 )
 const LIST_MAX = 0x00ffffff
@@ -22,6 +23,10 @@ func (blo *BlListObject) Append(obj BlObject) {
 func (blo *BlListObject) GetList() []BlObject {
     return blo.list
 }
+func (blo *BlListObject) Sort(key, cmp BlObject,
+                              reverse bool) int {
+    return blListSort(blo, key, cmp, reverse)
+}
 var blListSequence = BlSequenceMethods{
     SqItem      : blListItem,
     SqAssItem   : blListAssItem,
@@ -29,13 +34,26 @@ var blListSequence = BlSequenceMethods{
     SqSlice     : blListSlice,
     SqAssSlice  : blListAssSlice,
     SqSize      : blListSize,
+    SqContains  : blListContains,
 }
 var blListMethods = []BlGFunctionObject{
-    NewBlGFunction("append",  listAppend,  GFUNC_VARARGS),
-    NewBlGFunction("prepend", listPrepend, GFUNC_VARARGS),
-    NewBlGFunction("insert",  listInsert,  GFUNC_VARARGS),
-    NewBlGFunction("trunc",   listTrunc,   GFUNC_NOARGS ),
-    NewBlGFunction("reverse", listReverse, GFUNC_NOARGS ),
+    NewBlGFunction("append",   listAppend,   GFUNC_VARARGS),
+    NewBlGFunction("prepend",  listPrepend,  GFUNC_VARARGS),
+    NewBlGFunction("insert",   listInsert,   GFUNC_VARARGS),
+    NewBlGFunction("trunc",    listTrunc,    GFUNC_NOARGS ),
+    NewBlGFunction("reverse",  listReverse,  GFUNC_NOARGS ),
+    NewBlGFunction("pop",      listPop,      GFUNC_VARARGS),
+    NewBlGFunction("remove",   listRemove,   GFUNC_VARARGS),
+    NewBlGFunction("index",    listIndex,    GFUNC_VARARGS),
+    NewBlGFunction("count",    listCount,    GFUNC_VARARGS),
+    NewBlGFunction("extend",   listExtend,   GFUNC_VARARGS),
+    NewBlGFunction("contains", listContains, GFUNC_VARARGS),
+    NewBlGFunction("sort",     listSort,     GFUNC_VARARGS |
+                                             GFUNC_KEYWORDS),
+    NewBlGFunction("copy",     listCopy,     GFUNC_NOARGS ),
+    NewBlGFunction("map",      listMap,      GFUNC_VARARGS),
+    NewBlGFunction("filter",   listFilter,   GFUNC_VARARGS),
+    NewBlGFunction("reduce",   listReduce,   GFUNC_VARARGS),
 }
 var BlListType BlTypeObject
 
@@ -78,7 +96,7 @@ func blListRepeat(a, b BlObject) BlObject {
         return a
     }
     size := lobj.lsize * int(iobj.Value)
-    if size > LIST_MAX {
+    if size > LIST_MAX || iobj.Value > LIST_MAX {
         errpkg.SetErrmsg("repeated list became too large")
         return nil
     }
@@ -89,26 +107,16 @@ func blListRepeat(a, b BlObject) BlObject {
     return ret
 }
 
-func blListSlice(obj BlObject, s, e int) BlObject {
+func blListSlice(obj BlObject, s, e, step int) BlObject {
     lobj := obj.(*BlListObject)
-    if s < 0 {
-        s = 0
-    } else if s > lobj.lsize {
-        s = lobj.lsize
-    }
-    if e < s {
-        e = s
-    } else if e > lobj.lsize {
-        e = lobj.lsize
-    }
-    list := NewBlList(0)
-    for i := s; i < e; i++ {
-        list.Append(lobj.list[i])
+    list := NewBlList(blSliceSize(s, e, step))
+    for i := range list.list {
+        list.list[i] = lobj.list[s + i * step]
     }
     return list
 }
 
-func blListAssSlice(obj, value BlObject, s, e int) int {
+func blListAssSlice(obj, value BlObject, s, e, step int) int {
     lobj2, ok := value.(*BlListObject)
     if !ok {
         errpkg.SetErrmsg("expected list for slice assigment" +
@@ -116,31 +124,32 @@ func blListAssSlice(obj, value BlObject, s, e int) int {
         return -1
     }
     lobj := obj.(*BlListObject)
-    if s < 0 {
-        s = 0
-    } else if s > lobj.lsize {
-        s = lobj.lsize
-    }
-    if e < s {
-        e = s
-    } else if e > lobj.lsize {
-        e = lobj.lsize
-    }
     /*
-     * Algorithm below might work better with for loops;
-     * removing items, changing item locations etc..
+     * Stepped slices keep their size, every item is
+     * replaced by one of the assigned list.
      */
-    siz := lobj2.lsize - (e - s)
-    if siz < 0 {
-        lobj.list = append(lobj.list[s:e + siz], lobj.list[e:]...)
-        for i := s; i < e + siz; i++ {
-            lobj.list[i] = lobj2.list[i - s]
+    if step != 1 {
+        size := blSliceSize(s, e, step)
+        if lobj2.lsize != size {
+            errpkg.SetErrmsg("cannot assign list of size %d to" +
+                             " slice of size %d", lobj2.lsize, size)
+            return -1
+        }
+        list := make([]BlObject, size)
+        copy(list, lobj2.list)
+        for i, elem := range list {
+            lobj.list[s + i * step] = elem
         }
-    } else {
-        lobj.list = append(lobj.list[:s], append(lobj2.list,
-                           lobj.list[e:]...)...)
+        return 0
     }
-    lobj.lsize += siz
+    if e < s {
+        e = s
+    }
+    list := make([]BlObject, 0, lobj.lsize - (e - s) + lobj2.lsize)
+    list = append(list, lobj.list[:s]...)
+    list = append(list, lobj2.list...)
+    lobj.list = append(list, lobj.list[e:]...)
+    lobj.lsize = len(lobj.list)
     return 0
 }
 
@@ -148,6 +157,16 @@ func blListSize(obj BlObject) int {
     return obj.(*BlListObject).lsize
 }
 
+func blListContains(obj, item BlObject) int {
+    lobj := obj.(*BlListObject)
+    for _, elem := range lobj.list {
+        if elem == item || BlCompare(elem, item) == 0 {
+            return 1
+        }
+    }
+    return 0
+}
+
 func blListRepr(obj BlObject) *BlStringObject {
     lobj := obj.(*BlListObject)
     
@@ -293,6 +312,322 @@ func listReverse(self BlObject, args ...BlObject) BlObject {
     return BlNil
 }
 
+// Items are equal if they are the same or compare equal.
+func blListEqual(a, b BlObject) bool {
+    return a == b || BlCompare(a, b) == 0
+}
+
+/*
+ * Remove and return the item at 'pos', the last one by
+ * default. A negative position counts from the end.
+ */
+func listPop(self BlObject, args ...BlObject) BlObject {
+    lobj := self.(*BlListObject)
+    pos := int64(lobj.lsize - 1)
+    if blParseArguments("|i", args, &pos) == -1 {
+        return nil
+    }
+    if lobj.lsize == 0 {
+        errpkg.SetErrmsg("pop from empty list")
+        return nil
+    }
+    if pos < 0 {
+        pos += int64(lobj.lsize)
+    }
+    if pos < 0 || pos >= int64(lobj.lsize) {
+        errpkg.SetErrmsg("position out of bounds")
+        return nil
+    }
+    obj := lobj.list[pos]
+    lobj.list = append(lobj.list[:pos], lobj.list[pos + 1:]...)
+    lobj.lsize--
+    return obj
+}
+
+// Remove the first item that is equal to the argument.
+func listRemove(self BlObject, args ...BlObject) BlObject {
+    var obj BlObject
+    if blParseArguments("o", args, &obj) == -1 {
+        return nil
+    }
+    lobj := self.(*BlListObject)
+    for i, elem := range lobj.list {
+        if blListEqual(elem, obj) {
+            lobj.list = append(lobj.list[:i], lobj.list[i + 1:]...)
+            lobj.lsize--
+            return BlNil
+        }
+    }
+    errpkg.SetErrmsg("list.remove(x): x not in list")
+    return nil
+}
+
+/*
+ * The position of the first item equal to the argument,
+ * searching from 'start' up to 'end', or -1 like
+ * string.index.
+ */
+func listIndex(self BlObject, args ...BlObject) BlObject {
+    var obj BlObject
+    lobj := self.(*BlListObject)
+    start, end := int64(0), int64(lobj.lsize)
+    if blParseArguments("o|ii", args, &obj, &start,
+                        &end) == -1 {
+        return nil
+    }
+    for _, pos := range []*int64{&start, &end} {
+        if *pos < 0 {
+            *pos += int64(lobj.lsize)
+        }
+        if *pos < 0 {
+            *pos = 0
+        } else if *pos > int64(lobj.lsize) {
+            *pos = int64(lobj.lsize)
+        }
+    }
+    for i := start; i < end; i++ {
+        if blListEqual(lobj.list[i], obj) {
+            return NewBlInt(i)
+        }
+    }
+    return NewBlInt(-1)
+}
+
+func listCount(self BlObject, args ...BlObject) BlObject {
+    var obj BlObject
+    if blParseArguments("o", args, &obj) == -1 {
+        return nil
+    }
+    var count int64
+    for _, elem := range self.(*BlListObject).list {
+        if blListEqual(elem, obj) {
+            count++
+        }
+    }
+    return NewBlInt(count)
+}
+
+// Append every item of an iterable.
+func listExtend(self BlObject, args ...BlObject) BlObject {
+    var arg BlObject
+    if blParseArguments("o", args, &arg) == -1 {
+        return nil
+    }
+    typeobj := arg.BlType()
+    seq := typeobj.Sequence
+    if seq == nil || seq.SqItem == nil || seq.SqSize == nil {
+        errpkg.SetErrmsg("'%s' object is not iterable",
+                         typeobj.Name)
+        return nil
+    }
+    lobj := self.(*BlListObject)
+    // Read the size first, the list may extend itself.
+    size := seq.SqSize(arg)
+    for i := 0; i < size; i++ {
+        lobj.Append(seq.SqItem(arg, i))
+    }
+    return BlNil
+}
+
+func listContains(self BlObject, args ...BlObject) BlObject {
+    var obj BlObject
+    if blParseArguments("o", args, &obj) == -1 {
+        return nil
+    }
+    return NewBlBool(blListContains(self, obj) == 1)
+}
+
+// An item to sort and the position it started at.
+type blSortItem struct {
+    key  BlObject
+    elem BlObject
+    pos  int
+}
+
+/*
+ * Order two sort items, through 'cmp' if it is set and
+ * BlCompare if not. The result of 'cmp' has to be an int
+ * below, equal to or above 0. Returns -2 on error.
+ */
+func blSortCompare(a, b *blSortItem, cmp BlObject) int {
+    if cmp == nil {
+        return BlCompare(a.key, b.key)
+    }
+    ret := blCall(cmp, a.key, b.key)
+    if ret == nil {
+        return -2
+    }
+    iobj, ok := ret.(*BlIntObject)
+    if !ok {
+        errpkg.SetErrmsg("comparator must return int, found" +
+                         " '%s'", ret.BlType().Name)
+        return -2
+    }
+    // Value keeps the sign of big ints.
+    switch {
+    case iobj.Value < 0:
+        return -1
+    case iobj.Value > 0:
+        return 1
+    default:
+        return 0
+    }
+}
+
+/*
+ * Sort the list in place. Items are ordered by the result
+ * of 'key' if it is set, and compared by 'cmp' if it is
+ * set. The sort is stable, so equal items keep their order,
+ * also when reversed. If two items can't be ordered, the
+ * error names the position of one of them.
+ */
+func blListSort(lobj *BlListObject, key, cmp BlObject,
+                reverse bool) int {
+    items := make([]blSortItem, lobj.lsize)
+    for i, elem := range lobj.list {
+        items[i] = blSortItem{elem, elem, i}
+        if key != nil {
+            items[i].key = blCall(key, elem)
+            if items[i].key == nil {
+                return -1
+            }
+        }
+    }
+    var failed *blSortItem
+    sort.SliceStable(items, func(i, j int) bool {
+        if failed != nil {
+            return false
+        }
+        a, b := &items[i], &items[j]
+        if reverse {
+            a, b = b, a
+        }
+        ret := blSortCompare(a, b, cmp)
+        if ret == -2 {
+            failed = a
+            if b.pos > a.pos {
+                failed = b
+            }
+            return false
+        }
+        return ret < 0
+    })
+    if failed != nil {
+        errpkg.SetErrmsg("%s (at index %d)", errpkg.Errmsg,
+                         failed.pos)
+        return -1
+    }
+    // 'key' and 'cmp' may have changed the list under us.
+    if lobj.lsize != len(items) {
+        errpkg.SetErrmsg("list modified during sort")
+        return -1
+    }
+    for i := range items {
+        lobj.list[i] = items[i].elem
+    }
+    return 0
+}
+
+/*
+ * Sort the list in place, see blListSort. Takes the
+ * keywords 'key', 'cmp' and 'reverse'.
+ */
+func listSort(self BlObject, args ...BlObject) BlObject {
+    var key, cmp, reverse BlObject
+    args, ret := blParseKeywords(args, []string{"key", "cmp",
+                                 "reverse"}, &key, &cmp, &reverse)
+    if ret == -1 || blParseArguments("", args) == -1 {
+        return nil
+    }
+    if key == BlNil {
+        key = nil
+    }
+    if cmp == BlNil {
+        cmp = nil
+    }
+    rev := reverse != nil && blEvalCond(reverse)
+    if blListSort(self.(*BlListObject), key, cmp, rev) == -1 {
+        return nil
+    }
+    return BlNil
+}
+
+// A shallow copy of the list.
+func listCopy(self BlObject, args ...BlObject) BlObject {
+    lobj := self.(*BlListObject)
+    ret := NewBlList(lobj.lsize)
+    copy(ret.list, lobj.list)
+    return ret
+}
+
+// A new list of 'f(item)' for every item.
+func listMap(self BlObject, args ...BlObject) BlObject {
+    var fn BlObject
+    if blParseArguments("o", args, &fn) == -1 {
+        return nil
+    }
+    lobj := self.(*BlListObject)
+    ret := NewBlList(0)
+    for i := 0; i < lobj.lsize; i++ {
+        obj := blCall(fn, lobj.list[i])
+        if obj == nil {
+            return nil
+        }
+        ret.Append(obj)
+    }
+    return ret
+}
+
+// A new list of the items that 'f(item)' is true for.
+func listFilter(self BlObject, args ...BlObject) BlObject {
+    var fn BlObject
+    if blParseArguments("o", args, &fn) == -1 {
+        return nil
+    }
+    lobj := self.(*BlListObject)
+    ret := NewBlList(0)
+    for i := 0; i < lobj.lsize; i++ {
+        elem := lobj.list[i]
+        obj := blCall(fn, elem)
+        if obj == nil {
+            return nil
+        }
+        if blEvalCond(obj) {
+            ret.Append(elem)
+        }
+    }
+    return ret
+}
+
+/*
+ * Fold the list from the left with 'f(acc, item)'. The
+ * first item is the start value unless one is passed.
+ */
+func listReduce(self BlObject, args ...BlObject) BlObject {
+    var fn, acc BlObject
+    if blParseArguments("o|o", args, &fn, &acc) == -1 {
+        return nil
+    }
+    lobj := self.(*BlListObject)
+    i := 0
+    if acc == nil {
+        if lobj.lsize == 0 {
+            errpkg.SetErrmsg("reduce() of empty list with no" +
+                             " initial value")
+            return nil
+        }
+        acc = lobj.list[0]
+        i++
+    }
+    for ; i < lobj.lsize; i++ {
+        acc = blCall(fn, acc, lobj.list[i])
+        if acc == nil {
+            return nil
+        }
+    }
+    return acc
+}
+
 func blInitList() {
     BlListType = BlTypeObject{
         header   : blHeader{&BlTypeType},
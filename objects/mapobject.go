@@ -6,38 +6,48 @@ import (
     "github.com/Magnus9/blue/errpkg"
 )
 
-type MapPair struct {
-    key BlObject
-    val BlObject
-}
-
+/*
+ * Maps are hash tables, see hashTable. They are printed
+ * in the order the keys were inserted.
+ */
 type BlMapObject struct {
     header blHeader
-    m      map[int64]MapPair
-    mlen   int
+    hashTable
 }
 func (bmo *BlMapObject) BlType() *BlTypeObject {
     return bmo.header.typeobj
 }
 
 var blMapMapping = BlMappingMethods{
-    MpSize   : blMapSize,
-    MpItem   : blMapItem,
-    MpAssItem: blMapAssItem,
+    MpSize    : blMapSize,
+    MpItem    : blMapItem,
+    MpAssItem : blMapAssItem,
+    MpContains: blMapContains,
 }
 
+var blMapMethods = []BlGFunctionObject{
+    NewBlGFunction("keys",   mapKeys,   GFUNC_NOARGS ),
+    NewBlGFunction("values", mapValues, GFUNC_NOARGS ),
+    NewBlGFunction("items",  mapItems,  GFUNC_NOARGS ),
+    NewBlGFunction("get",    mapGet,    GFUNC_VARARGS),
+    NewBlGFunction("has",    mapHas,    GFUNC_VARARGS),
+    NewBlGFunction("delete", mapDelete, GFUNC_VARARGS),
+    NewBlGFunction("pop",    mapPop,    GFUNC_VARARGS),
+    NewBlGFunction("update", mapUpdate, GFUNC_VARARGS),
+    NewBlGFunction("clear",  mapClear,  GFUNC_NOARGS ),
+    NewBlGFunction("copy",   mapCopy,   GFUNC_NOARGS ),
+}
 var BlMapType BlTypeObject
 
 func NewBlMap() *BlMapObject {
     return &BlMapObject{
-        header: blHeader{&BlMapType},
-        m     : make(map[int64]MapPair),
-        mlen  : 0,
+        header   : blHeader{&BlMapType},
+        hashTable: newHashTable(),
     }
 }
 
 func blMapSize(obj BlObject) int {
-    return obj.(*BlMapObject).mlen
+    return obj.(*BlMapObject).size
 }
 
 func blMapItem(obj, key BlObject) BlObject {
@@ -46,31 +56,20 @@ func blMapItem(obj, key BlObject) BlObject {
         return nil
     }
     mobj := obj.(*BlMapObject)
-    pair, ok := mobj.m[hash]
-    if !ok {
+    i := mobj.lookup(key, hash)
+    if i == -1 {
         errpkg.SetErrmsg("key not found")
         return nil
     }
-    return pair.val
+    return mobj.entries[i].val
 }
 
 func blMapAssItem(obj, value, key BlObject) int {
-    hash := blObjectHash(key)
-    if hash == -1 {
-        return -1
-    }
-    mobj := obj.(*BlMapObject)
-    pair, ok := mobj.m[hash]
-    if ok {
-        pair.val = value
-    } else {
-        mobj.m[hash] = MapPair{
-            key: key,
-            val: value,
-        }
-        mobj.mlen++
-    }
-    return 0
+    return obj.(*BlMapObject).set(key, value)
+}
+
+func blMapContains(obj, key BlObject) int {
+    return obj.(*BlMapObject).contains(key)
 }
 
 func blMapRepr(obj BlObject) *BlStringObject {
@@ -79,27 +78,224 @@ func blMapRepr(obj BlObject) *BlStringObject {
     var buf bytes.Buffer
     buf.WriteByte('{')
     i := 0
-    for _, pair := range mobj.m {
+    mobj.each(func(key, val BlObject) {
         if i > 0 {
             buf.WriteString(", ")
         }
-        key := pair.key.BlType()
-        val := pair.val.BlType()
-        buf.WriteString(key.Repr(pair.key).Value)
+        buf.WriteString(key.BlType().Repr(key).Value)
         buf.WriteString("=>")
-        buf.WriteString(val.Repr(pair.val).Value)
+        buf.WriteString(val.BlType().Repr(val).Value)
         i++
-    }
+    })
     buf.WriteByte('}')
     return NewBlString(buf.String())
 }
 
+func blMapGetMember(obj BlObject, name string) BlObject {
+    return genericGetMember(obj.BlType(), name, obj)
+}
+
+func blMapEvalCond(obj BlObject) bool {
+    return obj.(*BlMapObject).size > 0
+}
+
+/*
+ * Maps are equal if they have the same keys, and the
+ * values of the keys are equal. They have no order.
+ */
+func blMapCompare(a, b BlObject) int {
+    aMobj := a.(*BlMapObject)
+    bMobj := b.(*BlMapObject)
+    if aMobj.size == bMobj.size {
+        equal := true
+        aMobj.each(func(key, val BlObject) {
+            if !equal {
+                return
+            }
+            other, _ := bMobj.get(key)
+            equal = other != nil && BlCompare(val, other) == 0
+        })
+        if equal {
+            return 0
+        }
+    }
+    errpkg.SetErrmsg("maps cannot be ordered")
+    return -2
+}
+
+/*
+ * The map's constructor takes another map to copy, or
+ * a sequence of (key, value) pairs.
+ */
+func blMapInit(obj *BlTypeObject, args ...BlObject) BlObject {
+    var arg BlObject
+    if blParseArguments("|o", args, &arg) == -1 {
+        return nil
+    }
+    mobj := NewBlMap()
+    if arg == nil {
+        return mobj
+    }
+    if other, ok := arg.(*BlMapObject); ok {
+        mobj.merge(&other.hashTable)
+        return mobj
+    }
+    typeobj := arg.BlType()
+    seq := typeobj.Sequence
+    if seq == nil || seq.SqItem == nil || seq.SqSize == nil {
+        errpkg.SetErrmsg("'%s' object is not iterable",
+                         typeobj.Name)
+        return nil
+    }
+    for i := 0; i < seq.SqSize(arg); i++ {
+        pair := seq.SqItem(arg, i)
+        pseq := pair.BlType().Sequence
+        if pseq == nil || pseq.SqItem == nil || pseq.SqSize == nil ||
+           pseq.SqSize(pair) != 2 {
+            errpkg.SetErrmsg("map element %d is not a (key, value)" +
+                             " pair", i)
+            return nil
+        }
+        if blMapAssItem(mobj, pseq.SqItem(pair, 1),
+                        pseq.SqItem(pair, 0)) == -1 {
+            return nil
+        }
+    }
+    return mobj
+}
+
+/*
+ * The beginning of map methods..
+ */
+func mapKeys(self BlObject, args ...BlObject) BlObject {
+    list := NewBlList(0)
+    self.(*BlMapObject).each(func(key, val BlObject) {
+        list.Append(key)
+    })
+    return list
+}
+
+func mapValues(self BlObject, args ...BlObject) BlObject {
+    list := NewBlList(0)
+    self.(*BlMapObject).each(func(key, val BlObject) {
+        list.Append(val)
+    })
+    return list
+}
+
+func mapItems(self BlObject, args ...BlObject) BlObject {
+    list := NewBlList(0)
+    self.(*BlMapObject).each(func(key, val BlObject) {
+        list.Append(NewBlTuple(key, val))
+    })
+    return list
+}
+
+func mapGet(self BlObject, args ...BlObject) BlObject {
+    var key, def BlObject
+    if blParseArguments("o|o", args, &key, &def) == -1 {
+        return nil
+    }
+    val, ok := self.(*BlMapObject).get(key)
+    if !ok {
+        return nil
+    }
+    if val == nil {
+        if def == nil {
+            return BlNil
+        }
+        return def
+    }
+    return val
+}
+
+func mapHas(self BlObject, args ...BlObject) BlObject {
+    var key BlObject
+    if blParseArguments("o", args, &key) == -1 {
+        return nil
+    }
+    ret := blMapContains(self, key)
+    if ret == -1 {
+        return nil
+    }
+    return NewBlBool(ret == 1)
+}
+
+func mapDelete(self BlObject, args ...BlObject) BlObject {
+    var key BlObject
+    if blParseArguments("o", args, &key) == -1 {
+        return nil
+    }
+    val, ok := self.(*BlMapObject).delete(key)
+    if !ok {
+        return nil
+    }
+    if val == nil {
+        errpkg.SetErrmsg("key not found")
+        return nil
+    }
+    return BlNil
+}
+
+/*
+ * Remove a key and return its value. A missing key is
+ * an error unless there is a default to return.
+ */
+func mapPop(self BlObject, args ...BlObject) BlObject {
+    var key, def BlObject
+    if blParseArguments("o|o", args, &key, &def) == -1 {
+        return nil
+    }
+    val, ok := self.(*BlMapObject).delete(key)
+    if !ok {
+        return nil
+    }
+    if val == nil {
+        if def == nil {
+            errpkg.SetErrmsg("key not found")
+        }
+        return def
+    }
+    return val
+}
+
+func mapUpdate(self BlObject, args ...BlObject) BlObject {
+    var arg BlObject
+    if blParseArguments("o", args, &arg) == -1 {
+        return nil
+    }
+    other, ok := arg.(*BlMapObject)
+    if !ok {
+        errpkg.SetErrmsg("expected map, found '%s'",
+                         arg.BlType().Name)
+        return nil
+    }
+    self.(*BlMapObject).merge(&other.hashTable)
+    return BlNil
+}
+
+func mapClear(self BlObject, args ...BlObject) BlObject {
+    self.(*BlMapObject).clear()
+    return BlNil
+}
+
+func mapCopy(self BlObject, args ...BlObject) BlObject {
+    mobj := NewBlMap()
+    mobj.merge(&self.(*BlMapObject).hashTable)
+    return mobj
+}
+
 func blInitMap() {
     BlMapType = BlTypeObject{
-        header : blHeader{&BlTypeType},
-        Name   : "map",
-        Repr   : blMapRepr,
-        Mapping: &blMapMapping,
+        header   : blHeader{&BlTypeType},
+        Name     : "map",
+        Repr     : blMapRepr,
+        GetMember: blMapGetMember,
+        EvalCond : blMapEvalCond,
+        Compare  : blMapCompare,
+        Init     : blMapInit,
+        Mapping  : &blMapMapping,
+        methods  : blMapMethods,
     }
     blTypeFinish(&BlMapType)
 }
\ No newline at end of file
@@ -0,0 +1,36 @@
+package objects
+
+import (
+    "os"
+    "testing"
+)
+
+func TestMain(m *testing.M) {
+    BlInitTypes()
+    os.Exit(m.Run())
+}
+
+// -1 is also the hash that marks an object unhashable.
+func TestNegativeIntKeys(t *testing.T) {
+    m := NewBlMap()
+    for i := int64(-3); i <= 1; i++ {
+        if blMapAssItem(m, NewBlInt(i * 10), NewBlInt(i)) == -1 {
+            t.Fatalf("setting key %d failed", i)
+        }
+    }
+    for i := int64(-3); i <= 1; i++ {
+        val := blMapItem(m, NewBlInt(i))
+        if val == nil {
+            t.Fatalf("key %d not found", i)
+        }
+        if v := val.(*BlIntObject).Value; v != i * 10 {
+            t.Errorf("key %d maps to %d, want %d", i, v, i * 10)
+        }
+    }
+    if blMapContains(m, NewBlInt(-1)) != 1 {
+        t.Errorf("-1 not in map")
+    }
+    if n := blMapSize(m); n != 5 {
+        t.Errorf("map has %d keys, want 5", n)
+    }
+}
@@ -2,6 +2,7 @@
 package objects
 
 import (
+    "sort"
     "strings"
     "github.com/Magnus9/blue/errpkg"
 )
@@ -43,19 +44,21 @@ type BlFields struct {
 }
 
 type BlNumberMethods struct {
-    NumNeg    unaryfunc
-    NumCompl  unaryfunc
-    NumOr     binaryfunc
-    NumAnd    binaryfunc
-    NumXor    binaryfunc
-    NumLshift binaryfunc
-    NumRshift binaryfunc
-    NumAdd    binaryfunc
-    NumSub    binaryfunc
-    NumMul    binaryfunc
-    NumDiv    binaryfunc
-    NumMod    binaryfunc
-    NumCoerce func(*BlObject, *BlObject) int
+    NumNeg      unaryfunc
+    NumCompl    unaryfunc
+    NumOr       binaryfunc
+    NumAnd      binaryfunc
+    NumXor      binaryfunc
+    NumLshift   binaryfunc
+    NumRshift   binaryfunc
+    NumAdd      binaryfunc
+    NumSub      binaryfunc
+    NumMul      binaryfunc
+    NumDiv      binaryfunc
+    NumMod      binaryfunc
+    NumFloorDiv binaryfunc
+    NumPow      binaryfunc
+    NumCoerce   func(*BlObject, *BlObject) int
 }
 
 type BlSequenceMethods struct {
@@ -64,14 +67,20 @@ type BlSequenceMethods struct {
     SqAssItem    func(BlObject, BlObject, int) int
     SqConcat     func(BlObject, BlObject) BlObject
     SqRepeat     func(BlObject, BlObject) BlObject
-    SqSlice      func(BlObject, int, int) BlObject
-    SqAssSlice   func(BlObject, BlObject, int, int) int
+    // Slices get the start, stop and step from Indices.
+    SqSlice      func(BlObject, int, int, int) BlObject
+    SqAssSlice   func(BlObject, BlObject, int, int, int) int
+    // Returns 1 if the item is in the sequence, 0 if not
+    // and -1 on error.
+    SqContains   func(BlObject, BlObject) int
 }
 
 type BlMappingMethods struct {
     MpSize       func(BlObject) int
     MpItem       func(BlObject, BlObject) BlObject
     MpAssItem    func(BlObject, BlObject, BlObject) int
+    // Like SqContains, for the keys.
+    MpContains   func(BlObject, BlObject) int
 }
 
 /*
@@ -111,6 +120,9 @@ func genericGetMember(typeobj *BlTypeObject,
     }
     f, ok := ret.(*BlGFunctionObject)
     if ok {
+        if (f.Flags & GFUNC_CLASS) != 0 {
+            return newBlGMethod(typeobj, typeobj, f)
+        }
         return newBlGMethod(typeobj, self, f)
     }
     return ret
@@ -124,11 +136,128 @@ func locate(typeobj *BlTypeObject, name string) BlObject {
     return ret
 }
 
+/*
+ * The names of every member a type provides, including
+ * the ones inherited from its base type, sorted.
+ */
+func BlMemberNames(typeobj *BlTypeObject) []string {
+    seen := make(map[string]struct{})
+    var names []string
+    for t := typeobj; t != nil; t = t.base {
+        for name := range t.members {
+            if _, ok := seen[name]; !ok {
+                seen[name] = struct{}{}
+                names = append(names, name)
+            }
+        }
+    }
+    sort.Strings(names)
+    return names
+}
+
+/*
+ * The names that can follow a '.' on a live object, i.e
+ * the symbols of a module, the members of an instance and
+ * its classes, or else the members of its type. Sorted.
+ */
+func BlObjectMemberNames(obj BlObject) []string {
+    seen := make(map[string]struct{})
+    var names []string
+    add := func(name string) {
+        if _, ok := seen[name]; !ok {
+            seen[name] = struct{}{}
+            names = append(names, name)
+        }
+    }
+    addClass := func(cobj *BlClassObject) {
+        for cobj != nil {
+            for name := range cobj.members {
+                add(name)
+            }
+            switch t := cobj.base.(type) {
+                case *BlClassObject:
+                    cobj = t
+                    continue
+                case *BlTypeObject:
+                    for _, name := range BlMemberNames(t) {
+                        add(name)
+                    }
+            }
+            cobj = nil
+        }
+    }
+    switch t := obj.(type) {
+        case *BlModuleObject:
+            for name := range t.Locals {
+                add(name)
+            }
+        case *BlInstanceObject:
+            for name := range t.members {
+                add(name)
+            }
+            addClass(t.class)
+        case *BlClassObject:
+            addClass(t)
+        case *BlTypeObject:
+            names = BlMemberNames(t)
+        default:
+            names = BlMemberNames(obj.BlType())
+    }
+    sort.Strings(names)
+    return names
+}
+
 func BlParseArguments(fmts string, args []BlObject,
                       values ...interface{}) int {
     return blParseArguments(fmts, args, values...)
 }
 
+func BlParseKeywords(args []BlObject, names []string,
+                     values ...*BlObject) ([]BlObject, int) {
+    return blParseKeywords(args, names, values...)
+}
+
+/*
+ * Used to parse the keyword arguments of GFUNC_KEYWORDS
+ * builtins. The map of keywords is split off the end of
+ * 'args' and the value of each name in 'names' is stored
+ * in 'values'. Other keywords are an error. Returns the
+ * positional arguments.
+ */
+func blParseKeywords(args []BlObject, names []string,
+                     values ...*BlObject) ([]BlObject, int) {
+    if len(names) != len(values) {
+        errpkg.InternError("expected exactly (%d) values" +
+                           ", got (%d)", len(names), len(values))
+    }
+    var mobj *BlMapObject
+    if len(args) > 0 {
+        mobj, _ = args[len(args) - 1].(*BlMapObject)
+    }
+    if mobj == nil {
+        errpkg.InternError("expected keyword map")
+    }
+    var unknown string
+    mobj.each(func(key, val BlObject) {
+        name := key.(*BlStringObject).Value
+        for i := range names {
+            if names[i] == name {
+                *values[i] = val
+                return
+            }
+        }
+        if unknown == "" {
+            unknown = name
+        }
+    })
+    if unknown != "" {
+        errpkg.SetErrmsg("unexpected keyword argument '%s'",
+                         unknown)
+        return nil, -1
+    }
+    return args[:len(args) - 1], 0
+}
+
 /*
  * Used to parse arguments for builtin functions.
  */
@@ -186,7 +315,9 @@ func blParseArguments(fmts string, args []BlObject,
         case 's':
             sobj, ok := args[argpos].(*BlStringObject)
             if !ok {
-                errpkg.SetErrmsg("expected string")
+                errpkg.SetErrmsg("expected string for argument %d," +
+                                 " found '%s'", argpos + 1,
+                                 args[argpos].BlType().Name)
                 return -1
             }
             sval, ok := values[argpos].(*string)
@@ -197,7 +328,13 @@ func blParseArguments(fmts string, args []BlObject,
         case 'i':
             iobj, ok := args[argpos].(*BlIntObject)
             if !ok {
-                errpkg.SetErrmsg("expected integer")
+                errpkg.SetErrmsg("expected integer for argument %d," +
+                                 " found '%s'", argpos + 1,
+                                 args[argpos].BlType().Name)
+                return -1
+            }
+            if iobj.IsBig() {
+                errpkg.SetErrmsg("integer too large")
                 return -1
             }
             ival, ok := values[argpos].(*int64)
@@ -208,7 +345,9 @@ func blParseArguments(fmts string, args []BlObject,
         case 'f':
             fobj, ok := args[argpos].(*BlFloatObject)
             if !ok {
-                errpkg.SetErrmsg("expected float")
+                errpkg.SetErrmsg("expected float for argument %d," +
+                                 " found '%s'", argpos + 1,
+                                 args[argpos].BlType().Name)
                 return -1
             }
             fval, ok := values[argpos].(*float64)
@@ -219,7 +358,9 @@ func blParseArguments(fmts string, args []BlObject,
         case 'b':
             bobj, ok := args[argpos].(*BlBoolObject)
             if !ok {
-                errpkg.SetErrmsg("expected boolean")
+                errpkg.SetErrmsg("expected boolean for argument %d," +
+                                 " found '%s'", argpos + 1,
+                                 args[argpos].BlType().Name)
                 return -1
             }
             bval, ok := values[argpos].(*bool)
@@ -317,6 +458,13 @@ func BlNumCoerce(a, b *BlObject) int {
     return -1
 }
 
+/*
+ * Returned by BlCompare for floats that have no order,
+ * when one of them is nan. Such floats are neither equal,
+ * below nor above each other.
+ */
+const CMP_UNORDERED = 2
+
 /*
  * Small and simple comparison function that returns
  * < 0 for LT, > 0 for GT and 0 for EQ. It will grow
@@ -324,6 +472,10 @@ func BlNumCoerce(a, b *BlObject) int {
  */
 func BlCompare(a, b BlObject) int {
     if a == b {
+        // nan isn't even equal to itself.
+        if fobj, ok := a.(*BlFloatObject); ok && fobj.value != fobj.value {
+            return CMP_UNORDERED
+        }
         return 0
     }
     aTobj := a.BlType()
@@ -348,6 +500,35 @@ out:
     return -2
 }
 
+/*
+ * Calls a function, method or builtin with evaluated
+ * arguments. Only the interpreter can run Blue code, so
+ * it sets this before it runs a program.
+ */
+var BlCall func(fn BlObject, args ...BlObject) BlObject
+
+// Like BlCall, for builtins that take callables.
+func blCall(fn BlObject, args ...BlObject) BlObject {
+    if BlCall == nil {
+        errpkg.SetErrmsg("'%s' object can't be called here",
+                         fn.BlType().Name)
+        return nil
+    }
+    return BlCall(fn, args...)
+}
+
+/*
+ * Objects that dont have an EvalCond function are
+ * true, like in the interpreter.
+ */
+func blEvalCond(obj BlObject) bool {
+    fn := obj.BlType().EvalCond
+    if fn == nil {
+        return true
+    }
+    return fn(obj)
+}
+
 /*
  * Used to make a hash value out of an object. Returns -1
  * if the object passed is not hashable.
@@ -371,8 +552,14 @@ func BlInitTypes() {
     blInitFloat()
     // Initialize the list type.
     blInitList()
+    // Initialize the tuple type.
+    blInitTuple()
+    // Initialize the bytes and bytearray types.
+    blInitBytes()
     // Initialize the map type.
     blInitMap()
+    // Initialize the set type.
+    blInitSet()
     // Initialize the range type.
     blInitRange()
     // Initialize the file type.
@@ -1,21 +1,109 @@
-
 package objects
 
 import (
+    "bytes"
     "fmt"
+    "math"
     "github.com/Magnus9/blue/errpkg"
 )
+const (
+    RANGE_MIN = -0x80000000
+    RANGE_MAX = 0x7fffffff
+)
+// Bits of Open, for the ends that were left out.
+const (
+    RANGE_OPEN_S = 1 << 0
+    RANGE_OPEN_E = 1 << 1
+)
+/*
+ * A range counts from S towards E by Step, E is left out
+ * unless Incl is set. As slice keys negative ends count
+ * from the end of the sequence, and ends that were left
+ * out stretch to the start or end, see Indices.
+ */
 type BlRangeObject struct {
     header blHeader
     S      int
     E      int
+    Step   int
+    Incl   bool
+    Open   int
 }
 func (bro *BlRangeObject) BlType() *BlTypeObject {
     return bro.header.typeobj
 }
+
+/*
+ * The start, stop and step of a slice of a sequence of
+ * 'size' items. start and stop are positions in the
+ * sequence, stop is not part of the slice and is -1 when
+ * a negative step runs past the start.
+ */
+func (bro *BlRangeObject) Indices(size int) (int, int, int) {
+    step := bro.Step
+    lower, upper := 0, size
+    if step < 0 {
+        lower, upper = -1, size - 1
+    }
+    clamp := func(pos int) int {
+        if pos < lower {
+            return lower
+        } else if pos > upper {
+            return upper
+        }
+        return pos
+    }
+    var s, e int
+    if (bro.Open & RANGE_OPEN_S) != 0 {
+        s = 0
+        if step < 0 {
+            s = upper
+        }
+    } else {
+        s = bro.S
+        if s < 0 {
+            s += size
+        }
+        s = clamp(s)
+    }
+    if (bro.Open & RANGE_OPEN_E) != 0 {
+        e = upper
+        if step < 0 {
+            e = lower
+        }
+    } else {
+        e = bro.E
+        if e < 0 {
+            e += size
+        }
+        // Clamped first so that stepping past it can't overflow.
+        if bro.Incl {
+            e = clamp(e) + blSign(step)
+        }
+        e = clamp(e)
+    }
+    return s, e, step
+}
+
+// The number of items in a slice made by Indices.
+func blSliceSize(s, e, step int) int {
+    if step > 0 && s < e {
+        return (e - s + step - 1) / step
+    }
+    if step < 0 && s > e {
+        return (s - e - step - 1) / -step
+    }
+    return 0
+}
+
 var blRangeSequence = BlSequenceMethods{
-    SqItem: blRangeItem,
-    SqSize: blRangeSize,
+    SqItem    : blRangeItem,
+    SqSize    : blRangeSize,
+    SqContains: blRangeContains,
+}
+var blRangeMethods = []BlGFunctionObject{
+    NewBlGFunction("contains", rangeContains, GFUNC_VARARGS),
+    NewBlGFunction("reverse",  rangeReverse,  GFUNC_NOARGS ),
 }
 var BlRangeType BlTypeObject
 
@@ -24,34 +112,179 @@ func NewBlRange(s, e int) *BlRangeObject {
         header: blHeader{&BlRangeType},
         S     : s,
         E     : e,
+        Step  : 1,
     }
 }
 
+/*
+ * A range with a step and the ends set by 'open' left
+ * out. Ends that are left out are filled in for counting,
+ * from the side 'step' counts from.
+ */
+func NewBlSteppedRange(s, e, step int, incl bool,
+                       open int) *BlRangeObject {
+    if step == 0 {
+        errpkg.SetErrmsg("range step cannot be zero")
+        return nil
+    }
+    if (open & RANGE_OPEN_S) != 0 {
+        s = 0
+        if step < 0 {
+            s = RANGE_MAX
+        }
+    }
+    if (open & RANGE_OPEN_E) != 0 {
+        e = RANGE_MAX
+        if step < 0 {
+            e = RANGE_MIN
+        }
+    }
+    if blRangeCount(s, e, step, incl) > math.MaxInt64 {
+        errpkg.SetErrmsg("range is too large")
+        return nil
+    }
+    return &BlRangeObject{
+        header: blHeader{&BlRangeType},
+        S     : s,
+        E     : e,
+        Step  : step,
+        Incl  : incl,
+        Open  : open,
+    }
+}
+
+func blSign(num int) int {
+    if num < 0 {
+        return -1
+    }
+    return 1
+}
+
 func blRangeItem(obj BlObject, num int) BlObject {
     robj := obj.(*BlRangeObject)
-    if num < 0 || num >= (robj.E - robj.S) {
+    if num < 0 || num >= blRangeSize(obj) {
         errpkg.SetErrmsg("subscript position out of bounds")
         return nil
     }
-    return NewBlInt(int64(robj.S + num))
+    return NewBlInt(int64(robj.S + num * robj.Step))
 }
 
 func blRangeSize(obj BlObject) int {
     robj := obj.(*BlRangeObject)
-    return robj.E - robj.S
+    return int(blRangeCount(robj.S, robj.E, robj.Step, robj.Incl))
+}
+
+/*
+ * The number of ints in a range. Counted unsigned, as the
+ * ends can be further apart than an int holds.
+ */
+func blRangeCount(s, e, step int, incl bool) uint64 {
+    var span, ustep uint64
+    if step > 0 && s <= e {
+        span, ustep = uint64(e) - uint64(s), uint64(step)
+    } else if step < 0 && s >= e {
+        span, ustep = uint64(s) - uint64(e), -uint64(step)
+    } else {
+        return 0
+    }
+    count := span / ustep
+    if incl {
+        if count == math.MaxUint64 {
+            return count
+        }
+        return count + 1
+    }
+    if span % ustep != 0 {
+        count++
+    }
+    return count
+}
+
+/*
+ * Ints are in a range if counting from S hits them. The
+ * bounds of a range always fit an int, so big ints never
+ * are. The distance from S is kept unsigned so it can't
+ * overflow.
+ */
+func blRangeContains(obj, item BlObject) int {
+    robj := obj.(*BlRangeObject)
+    iobj, ok := item.(*BlIntObject)
+    if !ok || iobj.IsBig() {
+        return 0
+    }
+    value := int(iobj.Value)
+    var diff, step uint64
+    if robj.Step > 0 {
+        if value < robj.S {
+            return 0
+        }
+        diff, step = uint64(value) - uint64(robj.S), uint64(robj.Step)
+    } else {
+        if value > robj.S {
+            return 0
+        }
+        diff, step = uint64(robj.S) - uint64(value), -uint64(robj.Step)
+    }
+    if diff % step == 0 && diff / step < uint64(blRangeSize(obj)) {
+        return 1
+    }
+    return 0
 }
 
 func blRangeRepr(obj BlObject) *BlStringObject {
     robj := obj.(*BlRangeObject)
-    return NewBlString(fmt.Sprintf("%d..%d", robj.S,
-                                   robj.E))
+    var buf bytes.Buffer
+    if (robj.Open & RANGE_OPEN_S) == 0 {
+        buf.WriteString(fmt.Sprintf("%d", robj.S))
+    }
+    if robj.Incl {
+        buf.WriteString("..=")
+    } else {
+        buf.WriteString("..")
+    }
+    if (robj.Open & RANGE_OPEN_E) == 0 {
+        buf.WriteString(fmt.Sprintf("%d", robj.E))
+    }
+    if robj.Step != 1 {
+        buf.WriteString(fmt.Sprintf("..%d", robj.Step))
+    }
+    return NewBlString(buf.String())
+}
+
+func blRangeGetMember(obj BlObject, name string) BlObject {
+    return genericGetMember(obj.BlType(), name, obj)
+}
+
+func rangeContains(obj BlObject, args ...BlObject) BlObject {
+    var item BlObject
+    if blParseArguments("o", args, &item) == -1 {
+        return nil
+    }
+    return NewBlBool(blRangeContains(obj, item) == 1)
+}
+
+/*
+ * The same numbers counted the other way, from the last
+ * one back to S.
+ */
+func rangeReverse(obj BlObject, args ...BlObject) BlObject {
+    robj := obj.(*BlRangeObject)
+    size := blRangeSize(obj)
+    if size == 0 {
+        return NewBlSteppedRange(robj.S, robj.S, -robj.Step, false, 0)
+    }
+    last := robj.S + (size - 1) * robj.Step
+    return NewBlSteppedRange(last, robj.S, -robj.Step, true, 0)
 }
 
 func blInitRange() {
     BlRangeType = BlTypeObject{
-        header  : blHeader{&BlTypeType},
-        Name    : "range",
-        Repr    : blRangeRepr,
-        Sequence: &blRangeSequence,
+        header   : blHeader{&BlTypeType},
+        Name     : "range",
+        Repr     : blRangeRepr,
+        GetMember: blRangeGetMember,
+        Sequence : &blRangeSequence,
+        methods  : blRangeMethods,
     }
-}
\ No newline at end of file
+    blTypeFinish(&BlRangeType)
+}
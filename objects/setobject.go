@@ -0,0 +1,313 @@
+package objects
+
+import (
+    "bytes"
+    "github.com/Magnus9/blue/errpkg"
+)
+
+/*
+ * Sets are hash tables, see hashTable, where every
+ * element is its own value. They keep the order the
+ * elements were added in.
+ */
+type BlSetObject struct {
+    header blHeader
+    hashTable
+}
+func (bso *BlSetObject) BlType() *BlTypeObject {
+    return bso.header.typeobj
+}
+
+var blSetNumbers = BlNumberMethods{
+    NumOr : blSetUnion,
+    NumAnd: blSetIntersection,
+    NumSub: blSetDifference,
+}
+var blSetSequence = BlSequenceMethods{
+    SqItem    : blSetItem,
+    SqSize    : blSetSize,
+    SqContains: blSetContains,
+}
+var blSetMethods = []BlGFunctionObject{
+    NewBlGFunction("add",          setAdd,          GFUNC_VARARGS),
+    NewBlGFunction("remove",       setRemove,       GFUNC_VARARGS),
+    NewBlGFunction("has",          setHas,          GFUNC_VARARGS),
+    NewBlGFunction("union",        setUnion,        GFUNC_VARARGS),
+    NewBlGFunction("intersection", setIntersection, GFUNC_VARARGS),
+    NewBlGFunction("difference",   setDifference,   GFUNC_VARARGS),
+    NewBlGFunction("clear",        setClear,        GFUNC_NOARGS ),
+    NewBlGFunction("copy",         setCopy,         GFUNC_NOARGS ),
+}
+var BlSetType BlTypeObject
+
+func NewBlSet() *BlSetObject {
+    return &BlSetObject{
+        header   : blHeader{&BlSetType},
+        hashTable: newHashTable(),
+    }
+}
+
+// Add an element, returns -1 if it isn't hashable.
+func (bso *BlSetObject) Add(obj BlObject) int {
+    return bso.set(obj, obj)
+}
+
+/*
+ * Make a set out of the elements of a sequence, or the
+ * keys of a map.
+ */
+func blSetFrom(obj BlObject) *BlSetObject {
+    sobj := NewBlSet()
+    switch t := obj.(type) {
+        case *BlSetObject:
+            sobj.merge(&t.hashTable)
+            return sobj
+        case *BlMapObject:
+            ret := 0
+            t.each(func(key, val BlObject) {
+                if ret != -1 {
+                    ret = sobj.Add(key)
+                }
+            })
+            if ret == -1 {
+                return nil
+            }
+            return sobj
+    }
+    typeobj := obj.BlType()
+    seq := typeobj.Sequence
+    if seq == nil || seq.SqItem == nil || seq.SqSize == nil {
+        errpkg.SetErrmsg("'%s' object is not iterable",
+                         typeobj.Name)
+        return nil
+    }
+    for i := 0; i < seq.SqSize(obj); i++ {
+        if sobj.Add(seq.SqItem(obj, i)) == -1 {
+            return nil
+        }
+    }
+    return sobj
+}
+
+func blSetItem(obj BlObject, num int) BlObject {
+    sobj := obj.(*BlSetObject)
+    if num < 0 || num >= sobj.size {
+        errpkg.SetErrmsg("subscript position out of bounds")
+        return nil
+    }
+    return sobj.nth(num)
+}
+
+func blSetSize(obj BlObject) int {
+    return obj.(*BlSetObject).size
+}
+
+func blSetContains(obj, item BlObject) int {
+    return obj.(*BlSetObject).contains(item)
+}
+
+func blSetOperand(obj BlObject) *BlSetObject {
+    sobj, ok := obj.(*BlSetObject)
+    if !ok {
+        errpkg.SetErrmsg("expected set, found '%s'",
+                         obj.BlType().Name)
+    }
+    return sobj
+}
+
+func blSetUnion(a, b BlObject) BlObject {
+    other := blSetOperand(b)
+    if other == nil {
+        return nil
+    }
+    sobj := NewBlSet()
+    sobj.merge(&a.(*BlSetObject).hashTable)
+    sobj.merge(&other.hashTable)
+    return sobj
+}
+
+/*
+ * The elements of 'a' that are in 'b' if 'in' is set,
+ * and the ones that aren't otherwise.
+ */
+func blSetFilter(a, b BlObject, in bool) BlObject {
+    other := blSetOperand(b)
+    if other == nil {
+        return nil
+    }
+    sobj := NewBlSet()
+    a.(*BlSetObject).each(func(key, val BlObject) {
+        if (other.contains(key) == 1) == in {
+            sobj.Add(key)
+        }
+    })
+    return sobj
+}
+
+func blSetIntersection(a, b BlObject) BlObject {
+    return blSetFilter(a, b, true)
+}
+
+func blSetDifference(a, b BlObject) BlObject {
+    return blSetFilter(a, b, false)
+}
+
+func blSetRepr(obj BlObject) *BlStringObject {
+    sobj := obj.(*BlSetObject)
+    if sobj.size == 0 {
+        return NewBlString("new set()")
+    }
+    var buf bytes.Buffer
+    buf.WriteByte('{')
+    i := 0
+    sobj.each(func(key, val BlObject) {
+        if i > 0 {
+            buf.WriteString(", ")
+        }
+        buf.WriteString(key.BlType().Repr(key).Value)
+        i++
+    })
+    buf.WriteByte('}')
+    return NewBlString(buf.String())
+}
+
+func blSetGetMember(obj BlObject, name string) BlObject {
+    return genericGetMember(obj.BlType(), name, obj)
+}
+
+func blSetEvalCond(obj BlObject) bool {
+    return obj.(*BlSetObject).size > 0
+}
+
+// Sets are equal if they have the same elements.
+func blSetCompare(a, b BlObject) int {
+    aSobj := a.(*BlSetObject)
+    bSobj := b.(*BlSetObject)
+    if aSobj.size == bSobj.size {
+        equal := true
+        aSobj.each(func(key, val BlObject) {
+            equal = equal && bSobj.contains(key) == 1
+        })
+        if equal {
+            return 0
+        }
+    }
+    errpkg.SetErrmsg("sets cannot be ordered")
+    return -2
+}
+
+/*
+ * The set's constructor takes an iterable object, or
+ * a map for its keys.
+ */
+func blSetInit(obj *BlTypeObject, args ...BlObject) BlObject {
+    var arg BlObject
+    if blParseArguments("|o", args, &arg) == -1 {
+        return nil
+    }
+    if arg == nil {
+        return NewBlSet()
+    }
+    if sobj := blSetFrom(arg); sobj != nil {
+        return sobj
+    }
+    return nil
+}
+
+/*
+ * The beginning of set methods..
+ */
+func setAdd(self BlObject, args ...BlObject) BlObject {
+    var obj BlObject
+    if blParseArguments("o", args, &obj) == -1 {
+        return nil
+    }
+    if self.(*BlSetObject).Add(obj) == -1 {
+        return nil
+    }
+    return BlNil
+}
+
+func setRemove(self BlObject, args ...BlObject) BlObject {
+    var obj BlObject
+    if blParseArguments("o", args, &obj) == -1 {
+        return nil
+    }
+    val, ok := self.(*BlSetObject).delete(obj)
+    if !ok {
+        return nil
+    }
+    if val == nil {
+        errpkg.SetErrmsg("element not found")
+        return nil
+    }
+    return BlNil
+}
+
+func setHas(self BlObject, args ...BlObject) BlObject {
+    var obj BlObject
+    if blParseArguments("o", args, &obj) == -1 {
+        return nil
+    }
+    ret := blSetContains(self, obj)
+    if ret == -1 {
+        return nil
+    }
+    return NewBlBool(ret == 1)
+}
+
+/*
+ * The methods take any iterable, where the operators
+ * only take sets.
+ */
+func setOperation(self BlObject, args []BlObject,
+                  fn binaryfunc) BlObject {
+    var obj BlObject
+    if blParseArguments("o", args, &obj) == -1 {
+        return nil
+    }
+    other := blSetFrom(obj)
+    if other == nil {
+        return nil
+    }
+    return fn(self, other)
+}
+
+func setUnion(self BlObject, args ...BlObject) BlObject {
+    return setOperation(self, args, blSetUnion)
+}
+
+func setIntersection(self BlObject, args ...BlObject) BlObject {
+    return setOperation(self, args, blSetIntersection)
+}
+
+func setDifference(self BlObject, args ...BlObject) BlObject {
+    return setOperation(self, args, blSetDifference)
+}
+
+func setClear(self BlObject, args ...BlObject) BlObject {
+    self.(*BlSetObject).clear()
+    return BlNil
+}
+
+func setCopy(self BlObject, args ...BlObject) BlObject {
+    sobj := NewBlSet()
+    sobj.merge(&self.(*BlSetObject).hashTable)
+    return sobj
+}
+
+func blInitSet() {
+    BlSetType = BlTypeObject{
+        header   : blHeader{&BlTypeType},
+        Name     : "set",
+        Repr     : blSetRepr,
+        GetMember: blSetGetMember,
+        EvalCond : blSetEvalCond,
+        Compare  : blSetCompare,
+        Init     : blSetInit,
+        Numbers  : &blSetNumbers,
+        Sequence : &blSetSequence,
+        methods  : blSetMethods,
+    }
+    blTypeFinish(&BlSetType)
+}
@@ -37,6 +37,8 @@ type BlSocketObject struct {
     f      *os.File
     // The abstract socket address.
     saddr  syscall.Sockaddr
+    // Set by the 'b' mode, reads give bytes.
+    binary bool
 }
 func (bso *BlSocketObject) BlType() *BlTypeObject {
     return bso.header.typeobj
@@ -91,8 +93,13 @@ func blSocketGetMember(obj BlObject, name string) BlObject {
 
 func blSocketInit(obj *BlTypeObject, args ...BlObject) BlObject {
     var domain, stype, proto int64
-    if blParseArguments("iii", args, &domain, &stype,
-                        &proto) == -1 {
+    var mode string
+    if blParseArguments("iii|s", args, &domain, &stype,
+                        &proto, &mode) == -1 {
+        return nil
+    }
+    if mode != "" && mode != "b" {
+        errpkg.SetErrmsg("unrecognized socket mode '%s'", mode)
         return nil
     }
     /*
@@ -119,7 +126,9 @@ func blSocketInit(obj *BlTypeObject, args ...BlObject) BlObject {
         errpkg.SetErrmsg(err.Error())
         return nil
     }
-    return NewBlSocket(fd, domain, stype, saddr)
+    sobj := NewBlSocket(fd, domain, stype, saddr)
+    sobj.binary = mode == "b"
+    return sobj
 }
 
 func socketAFUNIXConnect(self *BlSocketObject,
@@ -184,6 +193,25 @@ func socketAFINET6Connect(self *BlSocketObject,
     return -1
 }
 
+/*
+ * The address of an AF_INET or AF_INET6 socket is an
+ * addr-port pair, given as a tuple or a list.
+ */
+func socketAddrPair(arg BlObject) []BlObject {
+    switch t := arg.(type) {
+        case *BlTupleObject:
+            if len(t.tuple) == 2 {
+                return t.tuple
+            }
+        case *BlListObject:
+            if t.lsize == 2 {
+                return t.list
+            }
+    }
+    errpkg.SetErrmsg("expected tuple with addr-port pair")
+    return nil
+}
+
 func socketConnect(obj BlObject, args ...BlObject) BlObject {
     var arg BlObject
     if blParseArguments("o", args, &arg) == -1 {
@@ -198,14 +226,9 @@ func socketConnect(obj BlObject, args ...BlObject) BlObject {
         case AF_INET:
             fallthrough
         case AF_INET6:
-            lobj, ok := arg.(*BlListObject)
-            if !ok || lobj.lsize != 2 {
-                errpkg.SetErrmsg("expected list with addr-port" +
-                                 " pair")
+            if args = socketAddrPair(arg); args == nil {
                 return nil
             }
-            args = []BlObject{lobj.list[0],
-                              lobj.list[1]}
             var host string
             var port int64
             if blParseArguments("si", args, &host, &port) == -1 {
@@ -295,14 +318,9 @@ func socketBind(obj BlObject, args ...BlObject) BlObject {
         case AF_INET:
             fallthrough
         case AF_INET6:
-            lobj, ok := arg.(*BlListObject)
-            if !ok || lobj.lsize != 2 {
-                errpkg.SetErrmsg("expected list with addr-port" +
-                                 " pair")
+            if args = socketAddrPair(arg); args == nil {
                 return nil
             }
-            args = []BlObject{lobj.list[0],
-                              lobj.list[1]}
             var host string
             var port int64
             if blParseArguments("si", args, &host, &port) == -1 {
@@ -347,8 +365,17 @@ func socketAccept(obj BlObject, args ...BlObject) BlObject {
         errpkg.SetErrmsg(err.Error())
         return nil
     }
-    return NewBlSocket(fd, self.domain, self.stype,
-                       saddr)
+    sobj := NewBlSocket(fd, self.domain, self.stype, saddr)
+    sobj.binary = self.binary
+    return sobj
+}
+
+// The data read as bytes or a string, by the socket mode.
+func blSocketData(self *BlSocketObject, data []byte) BlObject {
+    if self.binary {
+        return NewBlBytes(data)
+    }
+    return NewBlString(string(data))
 }
 
 func socketRead(obj BlObject, args ...BlObject) BlObject {
@@ -361,25 +388,29 @@ func socketRead(obj BlObject, args ...BlObject) BlObject {
     n, err := self.f.Read(buf)
     if err != nil {
         /*
-         * If n == 0 we just return an empty
-         * string.
+         * If n == 0 we just return empty
+         * data.
          */
         if n == 0 {
-            return NewBlString("")
+            return blSocketData(self, []byte{})
         }
         errpkg.SetErrmsg(err.Error())
         return nil
     }
-    return NewBlString(string(buf))
+    return blSocketData(self, buf[:n])
 }
 
 func socketWrite(obj BlObject, args ...BlObject) BlObject {
-    var data string
-    if blParseArguments("s", args, &data) == -1 {
+    var arg BlObject
+    if blParseArguments("o", args, &arg) == -1 {
+        return nil
+    }
+    data := blWriteData(arg)
+    if data == nil {
         return nil
     }
     self := obj.(*BlSocketObject)
-    size, err := self.f.WriteString(data)
+    size, err := self.f.Write(data)
     if err != nil {
         errpkg.SetErrmsg(err.Error())
         return nil
@@ -388,15 +419,19 @@ func socketWrite(obj BlObject, args ...BlObject) BlObject {
 }
 
 func socketWriteAll(obj BlObject, args ...BlObject) BlObject {
-    var data string
-    if blParseArguments("s", args, &data) == -1 {
+    var arg BlObject
+    if blParseArguments("o", args, &arg) == -1 {
+        return nil
+    }
+    data := blWriteData(arg)
+    if data == nil {
         return nil
     }
     self := obj.(*BlSocketObject)
     dataLen := len(data)
     var pos int
     for {
-        siz, err := self.f.WriteString(data[pos:])
+        siz, err := self.f.Write(data[pos:])
         if err != nil {
             errpkg.SetErrmsg(err.Error())
             return nil
@@ -421,19 +456,15 @@ func socketGetAddress(obj BlObject, args ...BlObject) BlObject {
             saddr := self.saddr.(*syscall.SockaddrUnix)
             return NewBlString(saddr.Name)
         case AF_INET:
-            lobj := NewBlList(2)
             saddr := self.saddr.(*syscall.SockaddrInet4)
-            lobj.list[0] = NewBlString(
-                net.IP(saddr.Addr[:]).String())
-            lobj.list[1] = NewBlInt(int64(saddr.Port))
-            return lobj
+            return NewBlTuple(
+                NewBlString(net.IP(saddr.Addr[:]).String()),
+                NewBlInt(int64(saddr.Port)))
         case AF_INET6:
-            lobj := NewBlList(2)
             saddr := self.saddr.(*syscall.SockaddrInet6)
-            lobj.list[0] = NewBlString(
-                net.IP(saddr.Addr[:]).String())
-            lobj.list[1] = NewBlInt(int64(saddr.Port))
-            return lobj
+            return NewBlTuple(
+                NewBlString(net.IP(saddr.Addr[:]).String()),
+                NewBlInt(int64(saddr.Port)))
     }
     /*
      * Never reaches this state (hence the no default case)
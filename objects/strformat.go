@@ -0,0 +1,517 @@
+package objects
+
+import (
+    "math"
+    "bytes"
+    "strings"
+    "strconv"
+    "unicode/utf8"
+    "math/big"
+    "github.com/Magnus9/blue/errpkg"
+)
+
+/*
+ * A parsed format spec, the part after ':' in a
+ * placeholder of string.format:
+ *
+ *   [[fill]align][sign][#][0][width][.precision][type]
+ *
+ * align is one of '<', '>', '^' or '=', where '=' pads
+ * between the sign and the digits. sign is one of '+',
+ * '-' or ' '.
+ */
+type formatSpec struct {
+    fill      rune
+    align     byte
+    sign      byte
+    alt       bool
+    width     int
+    precision int
+    conv      byte
+}
+
+func newFormatSpec() *formatSpec {
+    return &formatSpec{fill: ' ', precision: -1}
+}
+
+func isFormatAlign(ch byte) bool {
+    return ch == '<' || ch == '>' || ch == '^' || ch == '='
+}
+
+// Scan a run of digits at 'pos', -1 if there are none.
+func scanFormatNumber(str string, pos int) (int, int) {
+    end := pos
+    for end < len(str) && str[end] >= '0' && str[end] <= '9' {
+        end++
+    }
+    if end == pos {
+        return -1, pos
+    }
+    num, err := strconv.Atoi(str[pos:end])
+    if err != nil || num > STRING_MAX {
+        return -1, pos
+    }
+    return num, end
+}
+
+func parseFormatSpec(str string) *formatSpec {
+    spec := newFormatSpec()
+    pos := 0
+    if ch, size := utf8.DecodeRuneInString(str); size > 0 &&
+       size < len(str) && isFormatAlign(str[size]) {
+        spec.fill, spec.align = ch, str[size]
+        pos = size + 1
+    } else if len(str) > 0 && isFormatAlign(str[0]) {
+        spec.align = str[0]
+        pos = 1
+    }
+    if pos < len(str) && strings.IndexByte("+- ", str[pos]) >= 0 {
+        spec.sign = str[pos]
+        pos++
+    }
+    if pos < len(str) && str[pos] == '#' {
+        spec.alt = true
+        pos++
+    }
+    if pos < len(str) && str[pos] == '0' {
+        if spec.align == 0 {
+            spec.fill, spec.align = '0', '='
+        }
+        pos++
+    }
+    if num, end := scanFormatNumber(str, pos); num != -1 {
+        spec.width, pos = num, end
+    }
+    if pos < len(str) && str[pos] == '.' {
+        num, end := scanFormatNumber(str, pos + 1)
+        if num == -1 {
+            goto err
+        }
+        spec.precision, pos = num, end
+    }
+    if pos < len(str) {
+        spec.conv = str[pos]
+        pos++
+    }
+    if pos == len(str) {
+        return spec
+    }
+err:
+    errpkg.SetErrmsg("invalid format spec '%s'", str)
+    return nil
+}
+
+/*
+ * The string form of an object. Strings are used as
+ * they are, instances go through their '__str__' method
+ * if they have one and everything else through Repr.
+ */
+func BlStr(obj BlObject) *BlStringObject {
+    switch t := obj.(type) {
+        case *BlStringObject:
+            return t
+        case *BlInstanceObject:
+            m, ok := blInstanceGetMember(t, "__str__").(*BlMethodObject)
+            if !ok || BlCall == nil {
+                break
+            }
+            ret := BlCall(m)
+            if ret == nil {
+                return nil
+            }
+            sobj, ok := ret.(*BlStringObject)
+            if !ok {
+                errpkg.SetErrmsg("__str__ returned '%s', expected" +
+                                 " string", ret.BlType().Name)
+                return nil
+            }
+            return sobj
+    }
+    typeobj := obj.BlType()
+    if typeobj.Repr == nil {
+        errpkg.SetErrmsg("'%s' object has no representation",
+                         typeobj.Name)
+        return nil
+    }
+    return typeobj.Repr(obj)
+}
+
+/*
+ * Pad 'body' out to the width of the spec. 'prefix' is
+ * the sign and base prefix of a number, which '=' keeps
+ * in front of the padding.
+ */
+func (spec *formatSpec) pad(prefix, body string, align byte) string {
+    if spec.align != 0 {
+        align = spec.align
+    }
+    n := spec.width - utf8.RuneCountInString(prefix + body)
+    if n <= 0 {
+        return prefix + body
+    }
+    fill := string(spec.fill)
+    switch align {
+        case '<':
+            return prefix + body + strings.Repeat(fill, n)
+        case '^':
+            return strings.Repeat(fill, n / 2) + prefix + body +
+                   strings.Repeat(fill, n - n / 2)
+        case '=':
+            return prefix + strings.Repeat(fill, n) + body
+    }
+    return strings.Repeat(fill, n) + prefix + body
+}
+
+func (spec *formatSpec) signOf(negative bool) string {
+    switch {
+        case negative:
+            return "-"
+        case spec.sign == '+':
+            return "+"
+        case spec.sign == ' ':
+            return " "
+    }
+    return ""
+}
+
+func (spec *formatSpec) formatInt(iobj *BlIntObject) string {
+    value := iobj.Big()
+    digits := new(big.Int).Abs(value)
+    prefix := spec.signOf(value.Sign() < 0)
+    var body string
+    switch spec.conv {
+        case 'x', 'X':
+            body = digits.Text(16)
+            if spec.alt {
+                prefix += "0x"
+            }
+        case 'o':
+            body = digits.Text(8)
+            if spec.alt {
+                prefix += "0o"
+            }
+        case 'b':
+            body = digits.Text(2)
+            if spec.alt {
+                prefix += "0b"
+            }
+        default:
+            body = digits.Text(10)
+    }
+    if spec.conv == 'X' {
+        prefix, body = strings.ToUpper(prefix), strings.ToUpper(body)
+    }
+    return spec.pad(prefix, body, '>')
+}
+
+func (spec *formatSpec) formatFloat(value float64) string {
+    conv, precision := spec.conv, spec.precision
+    if conv == 0 {
+        conv = 'g'
+    }
+    if conv == '%' {
+        value *= 100
+    }
+    if precision == -1 {
+        precision = 6
+    }
+    negative := math.Signbit(value)
+    if negative {
+        value = -value
+    }
+    verb := conv
+    if verb == '%' || verb == 'F' {
+        verb = 'f'
+    }
+    var body string
+    switch {
+        case math.IsNaN(value):
+            body = "nan"
+        case math.IsInf(value, 0):
+            body = "inf"
+        default:
+            body = strconv.FormatFloat(value, verb, precision, 64)
+    }
+    if conv == '%' {
+        body += "%"
+    }
+    return spec.pad(spec.signOf(negative), body, '>')
+}
+
+/*
+ * Format one object according to its spec, returns nil
+ * if the object can't be formatted that way.
+ */
+func blFormatValue(obj BlObject, spec *formatSpec) *string {
+    var str string
+    iobj, isInt := obj.(*BlIntObject)
+    fobj, isFloat := obj.(*BlFloatObject)
+    switch spec.conv {
+        case 'd', 'x', 'X', 'o', 'b':
+            if !isInt {
+                goto err
+            }
+            str = spec.formatInt(iobj)
+        case 'c':
+            if !isInt || iobj.IsBig() || iobj.Value < 0 ||
+               iobj.Value > utf8.MaxRune {
+                goto err
+            }
+            str = spec.pad("", string(rune(iobj.Value)), '<')
+        case 'e', 'E', 'f', 'F', 'g', 'G', '%':
+            switch {
+                case isInt:
+                    str = spec.formatFloat(iobj.Float())
+                case isFloat:
+                    str = spec.formatFloat(fobj.value)
+                default:
+                    goto err
+            }
+        case 0, 's', 'r':
+            switch {
+                case spec.conv == 0 && isInt:
+                    str = spec.formatInt(iobj)
+                case spec.conv == 0 && isFloat &&
+                     spec.precision != -1:
+                    str = spec.formatFloat(fobj.value)
+                default:
+                    var sobj *BlStringObject
+                    if spec.conv == 'r' {
+                        sobj = obj.BlType().Repr(obj)
+                    } else {
+                        sobj = BlStr(obj)
+                    }
+                    if sobj == nil {
+                        return nil
+                    }
+                    body := sobj.Value
+                    if spec.precision != -1 &&
+                       spec.precision < utf8.RuneCountInString(body) {
+                        body = string([]rune(body)[:spec.precision])
+                    }
+                    str = spec.pad("", body, '<')
+            }
+        default:
+            errpkg.SetErrmsg("unknown format type '%c'", spec.conv)
+            return nil
+    }
+    return &str
+err:
+    errpkg.SetErrmsg("format type '%c' can't format '%s'",
+                     spec.conv, obj.BlType().Name)
+    return nil
+}
+
+/*
+ * Look up a named placeholder in the map that holds the
+ * named arguments.
+ */
+func blFormatLookup(names *BlMapObject, name string) BlObject {
+    obj, _ := names.get(NewBlString(name))
+    if obj == nil {
+        errpkg.SetErrmsg("no value for the name '%s'", name)
+    }
+    return obj
+}
+
+/*
+ * The engine behind string.format. Placeholders look
+ * like '{field!conv:spec}', where every part is optional.
+ * field is a position in 'args', a name to look up in
+ * 'names', or empty for the next argument in line.
+ * '{{' and '}}' stand for a plain brace.
+ */
+func blFormat(format string, args []BlObject,
+              names *BlMapObject) *BlStringObject {
+    var buf bytes.Buffer
+    next, manual := 0, false
+    for i := 0; i < len(format); i++ {
+        ch := format[i]
+        if ch == '}' {
+            if i + 1 < len(format) && format[i + 1] == '}' {
+                i++
+                buf.WriteByte('}')
+                continue
+            }
+            errpkg.SetErrmsg("single '}' in format string")
+            return nil
+        }
+        if ch != '{' {
+            buf.WriteByte(ch)
+            continue
+        }
+        if i + 1 < len(format) && format[i + 1] == '{' {
+            i++
+            buf.WriteByte('{')
+            continue
+        }
+        end := strings.IndexByte(format[i:], '}')
+        if end == -1 {
+            errpkg.SetErrmsg("single '{' in format string")
+            return nil
+        }
+        field := format[i + 1:i + end]
+        i += end
+
+        spec := newFormatSpec()
+        if pos := strings.IndexByte(field, ':'); pos != -1 {
+            if spec = parseFormatSpec(field[pos + 1:]); spec == nil {
+                return nil
+            }
+            field = field[:pos]
+        }
+        if pos := strings.IndexByte(field, '!'); pos != -1 {
+            conv := field[pos + 1:]
+            if conv != "r" && conv != "s" {
+                errpkg.SetErrmsg("unknown conversion '!%s'", conv)
+                return nil
+            }
+            if spec.conv != 0 && spec.conv != 's' {
+                errpkg.SetErrmsg("'!%s' can't be used with format" +
+                                 " type '%c'", conv, spec.conv)
+                return nil
+            }
+            spec.conv = conv[0]
+            field = field[:pos]
+        }
+        var obj BlObject
+        if num, end := scanFormatNumber(field, 0); field == "" ||
+           num != -1 && end == len(field) {
+            if field == "" {
+                if manual && next == 0 {
+                    goto numbering
+                }
+                num = next
+                next++
+            } else {
+                if next > 0 {
+                    goto numbering
+                }
+                manual = true
+            }
+            if num >= len(args) {
+                errpkg.SetErrmsg("format index %d out of range", num)
+                return nil
+            }
+            obj = args[num]
+        } else if obj = blFormatLookup(names, field); obj == nil {
+            return nil
+        }
+        str := blFormatValue(obj, spec)
+        if str == nil {
+            return nil
+        }
+        buf.WriteString(*str)
+    }
+    return NewBlString(buf.String())
+numbering:
+    errpkg.SetErrmsg("cannot switch between automatic and" +
+                     " manual field numbering")
+    return nil
+}
+
+/*
+ * The engine behind the '%' operator, printf style.
+ * Directives look like '%(name)flags width.precision type',
+ * with the flags '-', '+', ' ', '0' and '#'. A tuple
+ * operand gives one argument per directive, a map gives
+ * the names, anything else is a single argument.
+ */
+func blFormatPrintf(format string, operand BlObject) *BlStringObject {
+    var args []BlObject
+    names, _ := operand.(*BlMapObject)
+    if tobj, ok := operand.(*BlTupleObject); ok {
+        args = tobj.tuple
+    } else {
+        args = []BlObject{operand}
+    }
+    var buf bytes.Buffer
+    next := 0
+    for i := 0; i < len(format); i++ {
+        if format[i] != '%' {
+            buf.WriteByte(format[i])
+            continue
+        }
+        i++
+        if i < len(format) && format[i] == '%' {
+            buf.WriteByte('%')
+            continue
+        }
+        var obj BlObject
+        if i < len(format) && format[i] == '(' {
+            end := strings.IndexByte(format[i:], ')')
+            if end == -1 {
+                errpkg.SetErrmsg("unterminated name in format string")
+                return nil
+            }
+            if obj = blFormatLookup(names, format[i + 1:i + end]);
+               obj == nil {
+                return nil
+            }
+            // Naming an argument uses up the map.
+            next = len(args)
+            i += end + 1
+        }
+        spec := newFormatSpec()
+    flags:
+        for ; i < len(format); i++ {
+            switch format[i] {
+                case '-':
+                    spec.align, spec.fill = '<', ' '
+                case '0':
+                    if spec.align != '<' {
+                        spec.align, spec.fill = '=', '0'
+                    }
+                case '+', ' ':
+                    if spec.sign != '+' {
+                        spec.sign = format[i]
+                    }
+                case '#':
+                    spec.alt = true
+                default:
+                    break flags
+            }
+        }
+        // Unlike format, printf pads on the left by default.
+        if spec.align == 0 {
+            spec.align = '>'
+        }
+        if num, end := scanFormatNumber(format, i); num != -1 {
+            spec.width, i = num, end
+        }
+        if i < len(format) && format[i] == '.' {
+            spec.precision, i = scanFormatNumber(format, i + 1)
+            if spec.precision == -1 {
+                spec.precision = 0
+            }
+        }
+        if i >= len(format) {
+            errpkg.SetErrmsg("incomplete format directive")
+            return nil
+        }
+        spec.conv = format[i]
+        if spec.conv == 'i' {
+            spec.conv = 'd'
+        }
+        if obj == nil {
+            if next >= len(args) {
+                errpkg.SetErrmsg("not enough arguments for format" +
+                                 " string")
+                return nil
+            }
+            obj = args[next]
+            next++
+        }
+        str := blFormatValue(obj, spec)
+        if str == nil {
+            return nil
+        }
+        buf.WriteString(*str)
+    }
+    if next < len(args) {
+        errpkg.SetErrmsg("not all arguments converted during" +
+                         " string formatting")
+        return nil
+    }
+    return NewBlString(buf.String())
+}
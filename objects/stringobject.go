@@ -5,34 +5,149 @@ import (
     "fmt"
     "bytes"
     "strings"
+    "unicode"
+    "unicode/utf8"
     "github.com/Magnus9/blue/errpkg"
 )
 const STRING_MAX = 0x00ffffff
 
+/*
+ * Strings hold UTF-8 and are indexed, sliced and measured
+ * by character. Bytes that aren't valid UTF-8 are kept as
+ * they are and count as one character each, so any data
+ * read from a file or socket can be taken apart and put
+ * back together without loss. Only ord() and the case
+ * conversions have to look at what a character is, ord()
+ * fails on an invalid byte and the case conversions leave
+ * it alone.
+ */
 type BlStringObject struct {
     header     blHeader
     Value      string
+    // The size in bytes, see Len for characters.
     vsize      int
     cachedHash int64
+    // Number of characters, -1 until counted.
+    nchars     int
+    // Byte offset of every character, only kept for strings
+    // that aren't all ASCII.
+    offsets    []int
 }
 func (bso *BlStringObject) BlType() *BlTypeObject {
     return bso.header.typeobj
 }
+
+// The number of characters in the string.
+func (bso *BlStringObject) Len() int {
+    if bso.nchars == -1 {
+        bso.nchars = utf8.RuneCountInString(bso.Value)
+        if bso.nchars != bso.vsize {
+            bso.offsets = make([]int, 0, bso.nchars + 1)
+            for i := 0; i < bso.vsize; {
+                bso.offsets = append(bso.offsets, i)
+                _, size := utf8.DecodeRuneInString(bso.Value[i:])
+                i += size
+            }
+            bso.offsets = append(bso.offsets, bso.vsize)
+        }
+    }
+    return bso.nchars
+}
+
+// The byte offset of character 'num', 0 <= num <= Len().
+func (bso *BlStringObject) offset(num int) int {
+    if bso.Len(); bso.offsets == nil {
+        return num
+    }
+    return bso.offsets[num]
+}
+
+// The character index of byte offset 'pos'.
+func (bso *BlStringObject) charIndex(pos int) int {
+    if bso.Len(); bso.offsets == nil {
+        return pos
+    }
+    return utf8.RuneCountInString(bso.Value[:pos])
+}
+
+/*
+ * Map every character of 'str' through 'fn'. Invalid bytes
+ * are copied over as they are, where strings.Map would turn
+ * them into U+FFFD.
+ */
+func mapChars(str string, fn func(rune) rune) string {
+    var buf strings.Builder
+    for i := 0; i < len(str); {
+        ch, size := utf8.DecodeRuneInString(str[i:])
+        if ch == utf8.RuneError && size == 1 {
+            buf.WriteByte(str[i])
+        } else {
+            buf.WriteRune(fn(ch))
+        }
+        i += size
+    }
+    return buf.String()
+}
+
+// Check every character of 'str', false if it is empty.
+func allChars(str string, fn func(rune) bool) bool {
+    for i := 0; i < len(str); {
+        ch, size := utf8.DecodeRuneInString(str[i:])
+        if ch == utf8.RuneError && size == 1 || !fn(ch) {
+            return false
+        }
+        i += size
+    }
+    return len(str) > 0
+}
 var blStringSequence = BlSequenceMethods{
-    SqItem  : blStringItem,
-    SqConcat: blStringConcat,
-    SqRepeat: blStringRepeat,
-    SqSlice : blStringSlice,
-    SqSize  : blStringSize,
+    SqItem    : blStringItem,
+    SqConcat  : blStringConcat,
+    SqRepeat  : blStringRepeat,
+    SqSlice   : blStringSlice,
+    SqSize    : blStringSize,
+    SqContains: blStringContains,
+}
+var blStringNumbers = BlNumberMethods{
+    NumMod: blStringFormatMod,
 }
 var blStringMethods = []BlGFunctionObject {
-    NewBlGFunction("index",      stringIndex,      GFUNC_VARARGS),
-    NewBlGFunction("split",      stringSplit,      GFUNC_VARARGS),
-    NewBlGFunction("concat",     stringConcat,     GFUNC_VARARGS),
-    NewBlGFunction("toupper",    stringToUpper,    GFUNC_NOARGS ),
-    NewBlGFunction("tolower",    stringToLower,    GFUNC_NOARGS ),
-    NewBlGFunction("startswith", stringStartsWith, GFUNC_VARARGS),
-    NewBlGFunction("endswith",   stringEndsWith,   GFUNC_VARARGS),
+    NewBlGFunction("index",       stringIndex,      GFUNC_VARARGS),
+    NewBlGFunction("split",       stringSplit,      GFUNC_VARARGS),
+    NewBlGFunction("concat",      stringConcat,     GFUNC_VARARGS),
+    NewBlGFunction("toupper",     stringToUpper,    GFUNC_NOARGS ),
+    NewBlGFunction("tolower",     stringToLower,    GFUNC_NOARGS ),
+    NewBlGFunction("startswith",  stringStartsWith, GFUNC_VARARGS),
+    NewBlGFunction("endswith",    stringEndsWith,   GFUNC_VARARGS),
+    NewBlGFunction("ord",         stringOrd,        GFUNC_NOARGS ),
+    NewBlGFunction("isalpha",     stringIsAlpha,    GFUNC_NOARGS ),
+    NewBlGFunction("isspace",     stringIsSpace,    GFUNC_NOARGS ),
+    NewBlGFunction("isupper",     stringIsUpper,    GFUNC_NOARGS ),
+    NewBlGFunction("islower",     stringIsLower,    GFUNC_NOARGS ),
+    NewBlGFunction("casefold",    stringCaseFold,   GFUNC_NOARGS ),
+    NewBlGFunction("equalfold",   stringEqualFold,  GFUNC_VARARGS),
+    NewBlGFunction("strip",       stringStrip,      GFUNC_VARARGS),
+    NewBlGFunction("lstrip",      stringLStrip,     GFUNC_VARARGS),
+    NewBlGFunction("rstrip",      stringRStrip,     GFUNC_VARARGS),
+    NewBlGFunction("replace",     stringReplace,    GFUNC_VARARGS),
+    NewBlGFunction("join",        stringJoin,       GFUNC_VARARGS),
+    NewBlGFunction("find",        stringFind,       GFUNC_VARARGS),
+    NewBlGFunction("rfind",       stringRFind,      GFUNC_VARARGS),
+    NewBlGFunction("count",       stringCount,      GFUNC_VARARGS),
+    NewBlGFunction("contains",    stringContains,   GFUNC_VARARGS),
+    NewBlGFunction("splitlines",  stringSplitLines, GFUNC_VARARGS),
+    NewBlGFunction("partition",   stringPartition,  GFUNC_VARARGS),
+    NewBlGFunction("pad",         stringPad,        GFUNC_VARARGS),
+    NewBlGFunction("center",      stringCenter,     GFUNC_VARARGS),
+    NewBlGFunction("repeat",      stringRepeat,     GFUNC_VARARGS),
+    NewBlGFunction("isdigit",     stringIsDigit,    GFUNC_NOARGS ),
+    NewBlGFunction("isalnum",     stringIsAlnum,    GFUNC_NOARGS ),
+    NewBlGFunction("title",       stringTitle,      GFUNC_NOARGS ),
+    NewBlGFunction("trim_prefix", stringTrimPrefix, GFUNC_VARARGS),
+    NewBlGFunction("trim_suffix", stringTrimSuffix, GFUNC_VARARGS),
+    NewBlGFunction("encode",      stringEncode,     GFUNC_VARARGS),
+    NewBlGFunction("format",      stringFormat,     GFUNC_VARARGS |
+                                                    GFUNC_KEYWORDS),
 }
 var BlStringType BlTypeObject
 
@@ -43,16 +158,17 @@ func NewBlString(value string) *BlStringObject {
         vsize     : len(value),
         // Set cachedHash to -1 (not cached yet)
         cachedHash: -1,
+        nchars    : -1,
     }
 }
 
 func blStringItem(obj BlObject, num int) BlObject {
     sobj := obj.(*BlStringObject)
-    if num >= sobj.vsize || num < 0 {
+    if num >= sobj.Len() || num < 0 {
         errpkg.SetErrmsg("subscript position out of bounds")
         return nil
     }
-    return NewBlString(string(sobj.Value[num]))
+    return NewBlString(sobj.Value[sobj.offset(num):sobj.offset(num + 1)])
 }
 
 func blStringConcat(a, b BlObject) BlObject {
@@ -66,6 +182,14 @@ func blStringConcat(a, b BlObject) BlObject {
     return NewBlString(sobj.Value + t.Value)
 }
 
+// The '%' operator, see blFormatPrintf.
+func blStringFormatMod(a, b BlObject) BlObject {
+    if sobj := blFormatPrintf(a.(*BlStringObject).Value, b); sobj != nil {
+        return sobj
+    }
+    return nil
+}
+
 func blStringRepeat(a, b BlObject) BlObject {
     iobj, ok := b.(*BlIntObject)
     if !ok {
@@ -77,13 +201,16 @@ func blStringRepeat(a, b BlObject) BlObject {
     if iobj.Value == 1 {
         return a
     }
+    if iobj.Value < 0 {
+        return NewBlString("")
+    }
     size := sobj.vsize * int(iobj.Value)
     /*
      * Maximum string size for now is 24bits. Funny thing
      * is to replace the buffer writing to string
      * concentation using the '+=' operator.
      */
-    if size > STRING_MAX {
+    if iobj.IsBig() || size > STRING_MAX || iobj.Value > STRING_MAX {
         errpkg.SetErrmsg("repeated string became too large")
         return nil
     }
@@ -94,27 +221,40 @@ func blStringRepeat(a, b BlObject) BlObject {
     return NewBlString(buf.String())
 }
 
-func blStringSlice(obj BlObject, s, e int) BlObject {
+func blStringSlice(obj BlObject, s, e, step int) BlObject {
     sobj := obj.(*BlStringObject)
-    if s < 0 {
-        s = 0
-    } else if s > sobj.vsize {
-        s = sobj.vsize
-    }
-    if e > sobj.vsize {
-        e = sobj.vsize
-    }
-    if s == 0 && e > sobj.vsize {
-        return obj
+    size := blSliceSize(s, e, step)
+    if step == 1 {
+        if size == sobj.Len() {
+            return obj
+        }
+        e = s + size
+        return NewBlString(sobj.Value[sobj.offset(s):sobj.offset(e)])
     }
-    if e < s {
-        e = s
+    var buf strings.Builder
+    for i := 0; i < size; i++ {
+        pos := s + i * step
+        buf.WriteString(sobj.Value[sobj.offset(pos):sobj.offset(pos + 1)])
     }
-    return NewBlString(sobj.Value[s:e])
+    return NewBlString(buf.String())
 }
 
 func blStringSize(obj BlObject) int {
-    return obj.(*BlStringObject).vsize
+    return obj.(*BlStringObject).Len()
+}
+
+// Strings contain their substrings.
+func blStringContains(obj, item BlObject) int {
+    sobj, ok := item.(*BlStringObject)
+    if !ok {
+        errpkg.SetErrmsg("'in <string>' requires a string, found" +
+                         " '%s'", item.BlType().Name)
+        return -1
+    }
+    if strings.Contains(obj.(*BlStringObject).Value, sobj.Value) {
+        return 1
+    }
+    return 0
 }
 
 func blStringRepr(obj BlObject) *BlStringObject {
@@ -140,9 +280,12 @@ func blStringCompare(a, b BlObject) int {
     aSobj := a.(*BlStringObject)
     bSobj := b.(*BlStringObject)
     for i := 0; i < aSobj.vsize && i < bSobj.vsize; i++ {
-        v := int(aSobj.Value[i]) - int(bSobj.Value[i])
-        if v != 0 {
-            return v
+        // Only the sign counts, -2 is kept for errors.
+        switch {
+        case aSobj.Value[i] < bSobj.Value[i]:
+            return -1
+        case aSobj.Value[i] > bSobj.Value[i]:
+            return 1
         }
     }
     switch {
@@ -160,6 +303,10 @@ func blStringHash(obj BlObject) int64 {
     if sobj.cachedHash != -1 {
         return sobj.cachedHash
     }
+    if sobj.vsize == 0 {
+        sobj.cachedHash = 0
+        return 0
+    }
     sum := int64(sobj.Value[0] << 7)
     for i := 1; i < sobj.vsize; i++ {
         sum = (1000003 * sum) ^ int64(sobj.Value[i])
@@ -239,7 +386,11 @@ func stringIndex(obj BlObject, args ...BlObject) BlObject {
         return nil
     }
     self := obj.(*BlStringObject)
-    return NewBlInt(int64(strings.Index(self.Value, str)))
+    pos := strings.Index(self.Value, str)
+    if pos == -1 {
+        return NewBlInt(-1)
+    }
+    return NewBlInt(int64(self.charIndex(pos)))
 }
 
 func stringConcat(obj BlObject,
@@ -255,29 +406,13 @@ func stringConcat(obj BlObject,
 func stringToUpper(obj BlObject,
                    args ...BlObject) BlObject {
     sobj := obj.(*BlStringObject)
-    var buf bytes.Buffer
-    for _, ch := range sobj.Value {
-        num := ch
-        if ch >= 'a' && ch <= 'z' {
-            num = ch - rune(32)
-        }
-        buf.WriteByte(byte(num))
-    }
-    return NewBlString(buf.String())
+    return NewBlString(mapChars(sobj.Value, unicode.ToUpper))
 }
 
 func stringToLower(obj BlObject,
                    args ...BlObject) BlObject {
     sobj := obj.(*BlStringObject)
-    var buf bytes.Buffer
-    for _, ch := range sobj.Value {
-        num := ch
-        if ch >= 'A' && ch <= 'Z' {
-            num = ch + rune(32)
-        }
-        buf.WriteByte(byte(num))
-    }
-    return NewBlString(buf.String())
+    return NewBlString(mapChars(sobj.Value, unicode.ToLower))
 }
 
 func stringStartsWith(obj BlObject,
@@ -288,10 +423,10 @@ func stringStartsWith(obj BlObject,
         return nil
     }
     self := obj.(*BlStringObject)
-    if s < int64(0) || s > int64(self.vsize) {
+    if s < int64(0) || s > int64(self.Len()) {
         return BlFalse
     }
-    if strings.HasPrefix(self.Value[s:], str) {
+    if strings.HasPrefix(self.Value[self.offset(int(s)):], str) {
         return BlTrue
     }
     return BlFalse
@@ -301,20 +436,451 @@ func stringEndsWith(obj BlObject,
                     args ...BlObject) BlObject {
     self := obj.(*BlStringObject)
     var str string
-    var s int64 = int64(self.vsize - 1)
+    var s int64 = int64(self.Len() - 1)
     if blParseArguments("s|i", args, &str, &s) == -1 {
         return nil
     }
     s++
-    if s < int64(0) || s > int64(self.vsize) {
+    if s < int64(0) || s > int64(self.Len()) {
         return BlFalse
     }
-    if strings.HasSuffix(self.Value[:s], str) {
+    if strings.HasSuffix(self.Value[:self.offset(int(s))], str) {
         return BlTrue
     }
     return BlFalse
 }
 
+/*
+ * Fill in the placeholders of the string, see blFormat.
+ * Named placeholders are looked up in a map passed as
+ * the last argument.
+ */
+/*
+ * Named placeholders are looked up in the keyword
+ * arguments, or in a map passed last if there are none.
+ */
+func stringFormat(obj BlObject, args ...BlObject) BlObject {
+    self := obj.(*BlStringObject)
+    names := args[len(args) - 1].(*BlMapObject)
+    args = args[:len(args) - 1]
+    if names.size == 0 && len(args) > 0 {
+        if mobj, ok := args[len(args) - 1].(*BlMapObject); ok {
+            names = mobj
+        }
+    }
+    sobj := blFormat(self.Value, args, names)
+    if sobj == nil {
+        return nil
+    }
+    return sobj
+}
+
+// The code point of a string of one character.
+func stringOrd(obj BlObject, args ...BlObject) BlObject {
+    self := obj.(*BlStringObject)
+    if self.Len() != 1 {
+        errpkg.SetErrmsg("ord() expected a character, found a" +
+                         " string of length %d", self.Len())
+        return nil
+    }
+    ch, size := utf8.DecodeRuneInString(self.Value)
+    if ch == utf8.RuneError && size == 1 {
+        errpkg.SetErrmsg("ord() of invalid UTF-8 byte 0x%02x",
+                         self.Value[0])
+        return nil
+    }
+    return NewBlInt(int64(ch))
+}
+
+func stringIsAlpha(obj BlObject, args ...BlObject) BlObject {
+    return NewBlBool(allChars(obj.(*BlStringObject).Value,
+                              unicode.IsLetter))
+}
+
+func stringIsSpace(obj BlObject, args ...BlObject) BlObject {
+    return NewBlBool(allChars(obj.(*BlStringObject).Value,
+                              unicode.IsSpace))
+}
+
+// True if there are cased characters and all are upper case.
+func stringIsUpper(obj BlObject, args ...BlObject) BlObject {
+    str := obj.(*BlStringObject).Value
+    return NewBlBool(strings.IndexFunc(str, unicode.IsUpper) != -1 &&
+                     strings.IndexFunc(str, unicode.IsLower) == -1)
+}
+
+func stringIsLower(obj BlObject, args ...BlObject) BlObject {
+    str := obj.(*BlStringObject).Value
+    return NewBlBool(strings.IndexFunc(str, unicode.IsLower) != -1 &&
+                     strings.IndexFunc(str, unicode.IsUpper) == -1)
+}
+
+/*
+ * Fold the case of the string, for comparing strings
+ * without regard to case. See blCaseFold.
+ */
+func stringCaseFold(obj BlObject, args ...BlObject) BlObject {
+    return NewBlString(blCaseFold(obj.(*BlStringObject).Value))
+}
+
+// Whether the strings are equal once their case is folded.
+func stringEqualFold(obj BlObject, args ...BlObject) BlObject {
+    var str string
+    if blParseArguments("s", args, &str) == -1 {
+        return nil
+    }
+    return NewBlBool(blCaseFold(obj.(*BlStringObject).Value) ==
+                     blCaseFold(str))
+}
+
+/*
+ * Strip characters from the ends of the string. Without
+ * 'chars' white space is stripped, otherwise any of the
+ * characters in it.
+ */
+func stringTrim(obj BlObject, args []BlObject, left,
+                right bool) BlObject {
+    var chars string
+    if blParseArguments("|s", args, &chars) == -1 {
+        return nil
+    }
+    cut := unicode.IsSpace
+    if len(args) > 0 {
+        cut = func(ch rune) bool {
+            return strings.ContainsRune(chars, ch)
+        }
+    }
+    str := obj.(*BlStringObject).Value
+    if left {
+        str = strings.TrimLeftFunc(str, cut)
+    }
+    if right {
+        str = strings.TrimRightFunc(str, cut)
+    }
+    return NewBlString(str)
+}
+
+func stringStrip(obj BlObject, args ...BlObject) BlObject {
+    return stringTrim(obj, args, true, true)
+}
+
+func stringLStrip(obj BlObject, args ...BlObject) BlObject {
+    return stringTrim(obj, args, true, false)
+}
+
+func stringRStrip(obj BlObject, args ...BlObject) BlObject {
+    return stringTrim(obj, args, false, true)
+}
+
+// Replace 'old' with 'new', every time or the first 'count'.
+func stringReplace(obj BlObject, args ...BlObject) BlObject {
+    var old, new string
+    var count int64 = -1
+    if blParseArguments("ss|i", args, &old, &new, &count) == -1 {
+        return nil
+    }
+    self := obj.(*BlStringObject)
+    return NewBlString(strings.Replace(self.Value, old, new,
+                                       int(count)))
+}
+
+// Join the strings of a sequence with the string between.
+func stringJoin(obj BlObject, args ...BlObject) BlObject {
+    var arg BlObject
+    if blParseArguments("o", args, &arg) == -1 {
+        return nil
+    }
+    typeobj := arg.BlType()
+    seq := typeobj.Sequence
+    if seq == nil || seq.SqItem == nil || seq.SqSize == nil {
+        errpkg.SetErrmsg("'%s' object is not iterable",
+                         typeobj.Name)
+        return nil
+    }
+    self := obj.(*BlStringObject)
+    var buf strings.Builder
+    for i := 0; i < seq.SqSize(arg); i++ {
+        elem := seq.SqItem(arg, i)
+        sobj, ok := elem.(*BlStringObject)
+        if !ok {
+            errpkg.SetErrmsg("join() expected string at index %d," +
+                             " found '%s'", i, elem.BlType().Name)
+            return nil
+        }
+        if i > 0 {
+            buf.WriteString(self.Value)
+        }
+        buf.WriteString(sobj.Value)
+    }
+    return NewBlString(buf.String())
+}
+
+/*
+ * Parse the 'sub[, start[, end]]' arguments of find and
+ * count. start and end are character positions, counted
+ * from the end if negative, and are turned into byte
+ * offsets of the string.
+ */
+func stringSearchArgs(self *BlStringObject, args []BlObject,
+                      sub *string, s, e *int) int {
+    size := int64(self.Len())
+    start, end := int64(0), size
+    if blParseArguments("s|ii", args, sub, &start, &end) == -1 {
+        return -1
+    }
+    for _, pos := range []*int64{&start, &end} {
+        if *pos < 0 {
+            *pos += size
+        }
+        if *pos < 0 {
+            *pos = 0
+        } else if *pos > size {
+            *pos = size
+        }
+    }
+    if end < start {
+        end = start
+    }
+    *s, *e = self.offset(int(start)), self.offset(int(end))
+    return 0
+}
+
+// The position of the first 'sub' in the string, or -1.
+func stringFind(obj BlObject, args ...BlObject) BlObject {
+    self := obj.(*BlStringObject)
+    var sub string
+    var s, e int
+    if stringSearchArgs(self, args, &sub, &s, &e) == -1 {
+        return nil
+    }
+    pos := strings.Index(self.Value[s:e], sub)
+    if pos == -1 {
+        return NewBlInt(-1)
+    }
+    return NewBlInt(int64(self.charIndex(s + pos)))
+}
+
+// The position of the last 'sub' in the string, or -1.
+func stringRFind(obj BlObject, args ...BlObject) BlObject {
+    self := obj.(*BlStringObject)
+    var sub string
+    var s, e int
+    if stringSearchArgs(self, args, &sub, &s, &e) == -1 {
+        return nil
+    }
+    pos := strings.LastIndex(self.Value[s:e], sub)
+    if pos == -1 {
+        return NewBlInt(-1)
+    }
+    return NewBlInt(int64(self.charIndex(s + pos)))
+}
+
+// The number of times 'sub' occurs without overlapping.
+func stringCount(obj BlObject, args ...BlObject) BlObject {
+    self := obj.(*BlStringObject)
+    var sub string
+    var s, e int
+    if stringSearchArgs(self, args, &sub, &s, &e) == -1 {
+        return nil
+    }
+    str := self.Value[s:e]
+    if sub == "" {
+        return NewBlInt(int64(utf8.RuneCountInString(str) + 1))
+    }
+    return NewBlInt(int64(strings.Count(str, sub)))
+}
+
+func stringContains(obj BlObject, args ...BlObject) BlObject {
+    var sub string
+    if blParseArguments("s", args, &sub) == -1 {
+        return nil
+    }
+    return NewBlBool(strings.Contains(obj.(*BlStringObject).Value,
+                                      sub))
+}
+
+/*
+ * Split the string at line breaks, '\n', '\r\n' or '\r'.
+ * The breaks are kept at the end of the lines if
+ * 'keepends' is set.
+ */
+func stringSplitLines(obj BlObject, args ...BlObject) BlObject {
+    var keepends bool
+    if blParseArguments("|b", args, &keepends) == -1 {
+        return nil
+    }
+    str := obj.(*BlStringObject).Value
+    lobj := NewBlList(0)
+    for len(str) > 0 {
+        end := strings.IndexAny(str, "\r\n")
+        if end == -1 {
+            lobj.Append(NewBlString(str))
+            break
+        }
+        next := end + 1
+        if str[end] == '\r' && next < len(str) && str[next] == '\n' {
+            next++
+        }
+        if keepends {
+            end = next
+        }
+        lobj.Append(NewBlString(str[:end]))
+        str = str[next:]
+    }
+    return lobj
+}
+
+/*
+ * Split the string at the first 'sep' into a tuple of
+ * the part before it, 'sep' and the part after it. If
+ * there is no 'sep' the tuple is (string, "", "").
+ */
+func stringPartition(obj BlObject, args ...BlObject) BlObject {
+    var sep string
+    if blParseArguments("s", args, &sep) == -1 {
+        return nil
+    }
+    if sep == "" {
+        errpkg.SetErrmsg("empty separator")
+        return nil
+    }
+    self := obj.(*BlStringObject)
+    pos := strings.Index(self.Value, sep)
+    if pos == -1 {
+        return NewBlTuple(self, NewBlString(""), NewBlString(""))
+    }
+    return NewBlTuple(NewBlString(self.Value[:pos]), NewBlString(sep),
+                      NewBlString(self.Value[pos + len(sep):]))
+}
+
+/*
+ * Parse the 'width[, fill]' arguments of pad and center.
+ * Returns the number of fill characters to add, -1 on
+ * error.
+ */
+func stringPadArgs(self *BlStringObject, args []BlObject,
+                   fill *string, more ...interface{}) int {
+    var width int64
+    fmts := "i|s"
+    if len(more) > 0 {
+        fmts += "b"
+    }
+    values := append([]interface{}{&width, fill}, more...)
+    if blParseArguments(fmts, args, values...) == -1 {
+        return -1
+    }
+    if utf8.RuneCountInString(*fill) != 1 {
+        errpkg.SetErrmsg("fill must be one character")
+        return -1
+    }
+    if width > STRING_MAX {
+        errpkg.SetErrmsg("padded string became too large")
+        return -1
+    }
+    if n := int(width) - self.Len(); n > 0 {
+        return n
+    }
+    return 0
+}
+
+/*
+ * Pad the string out to 'width' characters with 'fill',
+ * at the end, or in front if 'left' is set.
+ */
+func stringPad(obj BlObject, args ...BlObject) BlObject {
+    self := obj.(*BlStringObject)
+    fill, left := " ", false
+    n := stringPadArgs(self, args, &fill, &left)
+    if n == -1 {
+        return nil
+    }
+    if left {
+        return NewBlString(strings.Repeat(fill, n) + self.Value)
+    }
+    return NewBlString(self.Value + strings.Repeat(fill, n))
+}
+
+// Pad the string on both sides, the odd one at the end.
+func stringCenter(obj BlObject, args ...BlObject) BlObject {
+    self := obj.(*BlStringObject)
+    fill := " "
+    n := stringPadArgs(self, args, &fill)
+    if n == -1 {
+        return nil
+    }
+    return NewBlString(strings.Repeat(fill, n / 2) + self.Value +
+                       strings.Repeat(fill, n - n / 2))
+}
+
+// Like the '*' operator.
+func stringRepeat(obj BlObject, args ...BlObject) BlObject {
+    var num int64
+    if blParseArguments("i", args, &num) == -1 {
+        return nil
+    }
+    return blStringRepeat(obj, args[0])
+}
+
+func stringIsDigit(obj BlObject, args ...BlObject) BlObject {
+    return NewBlBool(allChars(obj.(*BlStringObject).Value,
+                              unicode.IsDigit))
+}
+
+func stringIsAlnum(obj BlObject, args ...BlObject) BlObject {
+    return NewBlBool(allChars(obj.(*BlStringObject).Value,
+                              func(ch rune) bool {
+        return unicode.IsLetter(ch) || unicode.IsDigit(ch)
+    }))
+}
+
+/*
+ * Upper case the first letter of every word and lower
+ * case the rest, where a word is a run of letters.
+ */
+func stringTitle(obj BlObject, args ...BlObject) BlObject {
+    inWord := false
+    return NewBlString(mapChars(obj.(*BlStringObject).Value,
+                                func(ch rune) rune {
+        wasInWord := inWord
+        inWord = unicode.IsLetter(ch)
+        if !inWord || wasInWord {
+            return unicode.ToLower(ch)
+        }
+        return unicode.ToTitle(ch)
+    }))
+}
+
+func stringTrimPrefix(obj BlObject, args ...BlObject) BlObject {
+    var prefix string
+    if blParseArguments("s", args, &prefix) == -1 {
+        return nil
+    }
+    return NewBlString(strings.TrimPrefix(obj.(*BlStringObject).Value,
+                                          prefix))
+}
+
+func stringTrimSuffix(obj BlObject, args ...BlObject) BlObject {
+    var suffix string
+    if blParseArguments("s", args, &suffix) == -1 {
+        return nil
+    }
+    return NewBlString(strings.TrimSuffix(obj.(*BlStringObject).Value,
+                                          suffix))
+}
+
+// Encode the string into bytes, see blEncode.
+func stringEncode(obj BlObject, args ...BlObject) BlObject {
+    var encoding string = "utf-8"
+    if blParseArguments("|s", args, &encoding) == -1 {
+        return nil
+    }
+    value := blEncode(obj.(*BlStringObject).Value, encoding)
+    if value == nil {
+        return nil
+    }
+    return NewBlBytes(value)
+}
+
 func blInitString() {
     BlStringType = BlTypeObject{
         header   : blHeader{&BlTypeType},
@@ -325,6 +891,7 @@ func blInitString() {
         Compare  : blStringCompare,
         hash     : blStringHash,
         Init     : blStringInit,
+        Numbers  : &blStringNumbers,
         Sequence : &blStringSequence,
         methods  : blStringMethods,
     }
@@ -0,0 +1,196 @@
+package objects
+
+import (
+    "bytes"
+    "github.com/Magnus9/blue/errpkg"
+)
+
+/*
+ * Tuples are lists that can't be changed once they
+ * are made, so unlike lists they can be hashed and used
+ * as map keys or set elements.
+ */
+type BlTupleObject struct {
+    header blHeader
+    tuple  []BlObject
+}
+func (bto *BlTupleObject) BlType() *BlTypeObject {
+    return bto.header.typeobj
+}
+func (bto *BlTupleObject) GetTuple() []BlObject {
+    return bto.tuple
+}
+var blTupleSequence = BlSequenceMethods{
+    SqItem      : blTupleItem,
+    SqConcat    : blTupleConcat,
+    SqSlice     : blTupleSlice,
+    SqSize      : blTupleSize,
+    SqContains  : blTupleContains,
+}
+var BlTupleType BlTypeObject
+
+func NewBlTuple(elems ...BlObject) *BlTupleObject {
+    return &BlTupleObject{
+        header: blHeader{&BlTupleType},
+        tuple : elems,
+    }
+}
+
+func blTupleItem(obj BlObject, num int) BlObject {
+    tobj := obj.(*BlTupleObject)
+    if num >= len(tobj.tuple) || num < 0 {
+        errpkg.SetErrmsg("subscript position out of bounds")
+        return nil
+    }
+    return tobj.tuple[num]
+}
+
+func blTupleConcat(a, b BlObject) BlObject {
+    tobj := a.(*BlTupleObject)
+    t, ok := b.(*BlTupleObject)
+    if !ok {
+        errpkg.SetErrmsg("cannot add '%s' to tuple",
+                         b.BlType().Name)
+        return nil
+    }
+    tuple := make([]BlObject, 0, len(tobj.tuple) + len(t.tuple))
+    tuple = append(tuple, tobj.tuple...)
+    return NewBlTuple(append(tuple, t.tuple...)...)
+}
+
+func blTupleSlice(obj BlObject, s, e, step int) BlObject {
+    tobj := obj.(*BlTupleObject)
+    tuple := make([]BlObject, blSliceSize(s, e, step))
+    for i := range tuple {
+        tuple[i] = tobj.tuple[s + i * step]
+    }
+    return NewBlTuple(tuple...)
+}
+
+func blTupleSize(obj BlObject) int {
+    return len(obj.(*BlTupleObject).tuple)
+}
+
+func blTupleContains(obj, item BlObject) int {
+    tobj := obj.(*BlTupleObject)
+    for _, elem := range tobj.tuple {
+        if elem == item || BlCompare(elem, item) == 0 {
+            return 1
+        }
+    }
+    return 0
+}
+
+func blTupleRepr(obj BlObject) *BlStringObject {
+    tobj := obj.(*BlTupleObject)
+
+    var buf bytes.Buffer
+    buf.WriteByte('(')
+    for i, elem := range tobj.tuple {
+        if i > 0 {
+            buf.WriteString(", ")
+        }
+        sobj := elem.BlType().Repr(elem)
+        buf.WriteString(sobj.Value)
+    }
+    // A tuple of one needs its comma, '(1)' is just 1.
+    if len(tobj.tuple) == 1 {
+        buf.WriteByte(',')
+    }
+    buf.WriteByte(')')
+    return NewBlString(buf.String())
+}
+
+func blTupleGetMember(obj BlObject, name string) BlObject {
+    return genericGetMember(obj.BlType(), name, obj)
+}
+
+func blTupleEvalCond(obj BlObject) bool {
+    return len(obj.(*BlTupleObject).tuple) > 0
+}
+
+// Tuples are ordered element by element, like lists.
+func blTupleCompare(a, b BlObject) int {
+    aTobj := a.(*BlTupleObject)
+    bTobj := b.(*BlTupleObject)
+    for i := 0; i < len(aTobj.tuple) && i < len(bTobj.tuple); i++ {
+        ret := BlCompare(aTobj.tuple[i], bTobj.tuple[i])
+        if ret != 0 {
+            return ret
+        }
+    }
+    switch {
+    case len(aTobj.tuple) < len(bTobj.tuple):
+        return -1
+    case len(aTobj.tuple) > len(bTobj.tuple):
+        return 1
+    default:
+        return 0
+    }
+}
+
+/*
+ * The hash is mixed from the hashes of the elements, so
+ * a tuple is only hashable if all of its elements are.
+ */
+func blTupleHash(obj BlObject) int64 {
+    tobj := obj.(*BlTupleObject)
+    size := int64(len(tobj.tuple))
+    sum, mult := int64(0x345678), int64(1000003)
+    for _, elem := range tobj.tuple {
+        hash := blObjectHash(elem)
+        if hash == -1 {
+            errpkg.SetErrmsg("tuple with '%s' element is not hashable",
+                             elem.BlType().Name)
+            return -1
+        }
+        sum = (sum ^ hash) * mult
+        mult += 82520 + size + size
+    }
+    sum += 97531
+    if sum == -1 {
+        sum = -2
+    }
+    return sum
+}
+
+/*
+ * The tuple's constructor takes an object that is
+ * iterable, like the list's.
+ */
+func blTupleInit(obj *BlTypeObject, args ...BlObject) BlObject {
+    var arg BlObject
+    if blParseArguments("|o", args, &arg) == -1 {
+        return nil
+    }
+    if arg == nil {
+        return NewBlTuple()
+    }
+    typeobj := arg.BlType()
+    seq := typeobj.Sequence
+    if seq == nil || seq.SqItem == nil || seq.SqSize == nil {
+        errpkg.SetErrmsg("'%s' object is not iterable",
+                         typeobj.Name)
+        return nil
+    }
+    tuple := make([]BlObject, seq.SqSize(arg))
+    for i := range tuple {
+        tuple[i] = seq.SqItem(arg, i)
+    }
+    return NewBlTuple(tuple...)
+}
+
+func blInitTuple() {
+    BlTupleType = BlTypeObject{
+        header   : blHeader{&BlTypeType},
+        Name     : "tuple",
+        Repr     : blTupleRepr,
+        GetMember: blTupleGetMember,
+        EvalCond : blTupleEvalCond,
+        Compare  : blTupleCompare,
+        Init     : blTupleInit,
+        Sequence : &blTupleSequence,
+        hash     : blTupleHash,
+    }
+    blTypeFinish(&BlTupleType)
+}
@@ -31,6 +31,9 @@ func blTypeGetMember(obj BlObject,
     }
     f, ok := ret.(*BlGFunctionObject)
     if ok {
+        if (f.Flags & GFUNC_CLASS) != 0 {
+            return newBlGMethod(typeobj, typeobj, f)
+        }
         return newBlGMethod(typeobj, nil, f)
     }
     return ret
@@ -0,0 +1,109 @@
+package parser
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "github.com/Magnus9/blue/token"
+)
+
+/*
+ * A syntax error found by the scanner or the parser.
+ * Col and EndCol count from 1 and mark the text the
+ * error is about. Expected and Found describe the
+ * tokens involved if the error is about a token, and are
+ * empty otherwise.
+ */
+type ParseError struct {
+    Pathname   string
+    LineNum    int
+    Col        int
+    EndCol     int
+    Expected   string
+    Found      string
+    Message    string
+    // The text of the line the error is on.
+    Line       string
+    // Set if the error is at the end of the input, so
+    // the program could go on to be valid.
+    Incomplete bool
+}
+
+func (e *ParseError) Error() string {
+    var buf strings.Builder
+    fmt.Fprintf(&buf, "%s:%d => ", e.Pathname, e.LineNum)
+    if e.Found != "" {
+        buf.WriteString("unexpected " + e.Found + ", ")
+    }
+    buf.WriteString(e.Message)
+    buf.WriteByte('\n')
+    buf.WriteString(token.Caret("   ", e.Line, e.Col, e.EndCol))
+
+    return buf.String()
+}
+
+/*
+ * Every error found in one pass over a program, in
+ * source order. A nil ErrorList is never returned as
+ * an error.
+ */
+type ErrorList []*ParseError
+
+func (l ErrorList) Error() string {
+    msgs := make([]string, len(l))
+    for i, e := range l {
+        msgs[i] = e.Error()
+    }
+    return strings.Join(msgs, "\n")
+}
+
+func (l ErrorList) sort() {
+    sort.SliceStable(l, func(i, j int) bool {
+        if l[i].LineNum != l[j].LineNum {
+            return l[i].LineNum < l[j].LineNum
+        }
+        return l[i].Col < l[j].Col
+    })
+}
+
+func (l ErrorList) err() error {
+    if len(l) == 0 {
+        return nil
+    }
+    l.sort()
+    return l
+}
+
+/*
+ * Report whether the program needs more input, i.e it
+ * ends in an open block, bracket or string. The REPL
+ * reads more lines as long as this holds, even if there
+ * are errors earlier on, so they are reported once the
+ * whole statement is in.
+ */
+func (l ErrorList) Incomplete() bool {
+    return len(l) > 0 && l[len(l) - 1].Incomplete
+}
+
+func IsIncomplete(err error) bool {
+    list, ok := err.(ErrorList)
+    return ok && list.Incomplete()
+}
+
+// How a token is named in error messages.
+func describeToken(tok token.Token) string {
+    tokenType := tok.TokenType
+    switch {
+        case tokenType == token.NEWLINE:
+            return "newline"
+        case tokenType == token.EOF:
+            return "end-of-file"
+        case tokenType == token.NAME:
+            return "name near '" + tok.Str + "'"
+        case tokenType >= token.STRING && tokenType <= token.NIL:
+            return "literal near '" + tok.Str + "'"
+        case tokenType >= token.DEF && tokenType <= token.NEW:
+            return "keyword near '" + tok.Str + "'"
+    }
+    return "symbol near '" + tok.Str + "'"
+}
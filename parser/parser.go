@@ -2,8 +2,7 @@
 package parser
 
 import "os"
-import "fmt"
-import "bytes"
+import "strings"
 import "github.com/Magnus9/blue/token"
 import "github.com/Magnus9/blue/interm"
 
@@ -12,6 +11,10 @@ type Parser struct {
     current  token.Token
     next     token.Token
     pathname string
+    // Number of tokens consumed, used to tell if recovery
+    // made progress.
+    pos      int
+    errors   ErrorList
 }
 
 func readFp(fp *os.File) string {
@@ -25,68 +28,228 @@ func readFp(fp *os.File) string {
     return string(buf)
 }
 
-func ParseFromFile(pathname string, fp *os.File) *interm.Node {
-    scanner := newScanner(readFp(fp), pathname)
-    p := Parser{
-        scanner : scanner,
-        current : scanner.nextToken(),
-        next    : scanner.nextToken(),
+func newParser(program, pathname string) *Parser {
+    p := &Parser{
+        scanner : newScanner(program, pathname),
         pathname: pathname,
     }
+    p.current = p.scanner.nextToken()
+    p.next    = p.scanner.nextToken()
+
+    return p
+}
+
+/*
+ * The entry points return the tree along with every
+ * syntax error found in it as an ErrorList. The tree
+ * lacks the statements that had errors, but is still
+ * fine for tooling to look at.
+ */
+func ParseFromFile(pathname string, fp *os.File) (*interm.Node,
+                                                  error) {
+    p := newParser(readFp(fp), pathname)
     root := interm.New("FILE_INPUT", "", token.FILE_INPUT,
                        0)
-    return p.Program(root)
+    root = p.Program(root)
+
+    return root, p.err()
 }
 
-func ParseFromRepl(pathname, program string) *interm.Node {
-    scanner := newScanner(program, pathname)
-    p := Parser{
-        scanner : scanner,
-        current : scanner.nextToken(),
-        next    : scanner.nextToken(),
-        pathname: pathname,
-    }
+func ParseFromRepl(pathname, program string) (*interm.Node, error) {
+    p := newParser(program, pathname)
     root := interm.New("INTERACTIVE", "", token.INTERACTIVE,
                        0)
-    return p.Program(root)
+    root = p.Program(root)
+
+    return root, p.err()
+}
+
+/*
+ * Parse a whole file and hand back the comments the
+ * scanner collected on the way, in source order. Used
+ * by tooling that has to reproduce the source.
+ */
+func ParseWithComments(pathname,
+                       program string) (*interm.Node, []token.Comment,
+                                        error) {
+    p := newParser(program, pathname)
+    root := interm.New("FILE_INPUT", "", token.FILE_INPUT,
+                       0)
+    root = p.Program(root)
+
+    return root, p.scanner.comments, p.err()
+}
+
+func (p *Parser) err() error {
+    errors := append(p.scanner.errors, p.errors...)
+    return errors.err()
 }
 
 func (p *Parser) createNode(str string,
                             nodeType int) *interm.Node {
-    return interm.New(str, p.current.Line, nodeType,
-                      p.current.LineNum)
+    node := interm.New(str, p.current.Line, nodeType,
+                       p.current.LineNum)
+    node.Col    = p.current.Col
+    node.EndCol = p.current.EndCol
+
+    return node
 }
 
+// Note the token that closes 'node', before it is matched.
+func (p *Parser) closeNode(node *interm.Node) {
+    node.EndLineNum = p.current.LineNum
+    node.Extend(p.current.LineNum, p.current.Col,
+                p.current.EndCol)
+}
+
+/*
+ * Abort the statement being parsed. The panic is caught
+ * by parseStmt, which records the error and skips ahead
+ * to where parsing can go on.
+ */
 func (p *Parser) postError(message string) {
-    var buf bytes.Buffer
-    buf.WriteString(fmt.Sprintf("%s:%d => ", p.pathname,
-                    p.current.LineNum))
-
-    tokenType := p.current.TokenType
-    if tokenType == token.NEWLINE {
-        buf.WriteString("unexpected newline, ")
-    } else if tokenType == token.EOF {
-        buf.WriteString("unexpected end-of-file, ")
-    } else if tokenType == token.NAME {
-        buf.WriteString("unexpected name near '" +
-                        p.current.Str + "', ")
-    } else if tokenType >= token.STRING &&
-              tokenType <= token.NIL {
-        buf.WriteString("unexpected literal near '" +
-                        p.current.Str + "', ")
-    } else if tokenType >= token.DEF &&
-              tokenType <= token.NEW {
-        buf.WriteString("unexpected keyword near '" +
-                        p.current.Str + ", ")
-    } else {
-        buf.WriteString("unexpected symbol near '" +
-                        p.current.Str + "', ")
+    panic(p.newError(message))
+}
+
+// An error about the current token.
+func (p *Parser) newError(message string) *ParseError {
+    perr := &ParseError{
+        Pathname  : p.pathname,
+        LineNum   : p.current.LineNum,
+        Col       : p.current.Col,
+        EndCol    : p.current.EndCol,
+        Found     : describeToken(p.current),
+        Message   : message,
+        Line      : p.current.Line,
+        // Whatever was expected could still come.
+        Incomplete: p.current.TokenType == token.EOF,
+    }
+    if strings.HasPrefix(message, "expected ") {
+        perr.Expected = expectedToken(message)
+    }
+    return perr
+}
+
+// The thing that was expected in a message like "expected X to ...".
+func expectedToken(message string) string {
+    str := strings.TrimPrefix(message, "expected ")
+    for _, sep := range []string{" to ", " after ", " as "} {
+        if pos := strings.Index(str, sep); pos > 0 {
+            str = str[:pos]
+        }
+    }
+    return str
+}
+
+/*
+ * Keep the first error of every line. Anything after it
+ * on the same line tends to be caused by it. Running into
+ * the end of the input is kept apart, once, since it says
+ * that something is left open.
+ */
+func (p *Parser) record(perr *ParseError) {
+    for _, list := range []ErrorList{p.scanner.errors, p.errors} {
+        for _, e := range list {
+            if e.Incomplete == perr.Incomplete &&
+               (perr.Incomplete || e.LineNum == perr.LineNum) {
+                return
+            }
+        }
+    }
+    p.errors = append(p.errors, perr)
+}
+
+/*
+ * Parse a statement and what terminates it. If there
+ * is a syntax error it is recorded, and the tokens up
+ * to the next statement boundary are skipped. Returns
+ * nil for a statement that had an error.
+ */
+func (p *Parser) parseStmt(parse func() *interm.Node,
+                           trailer func()) (node *interm.Node) {
+    opener, start := p.peekCurrent(), p.pos
+    done := false
+    defer func() {
+        if e := recover(); e != nil {
+            perr, ok := e.(*ParseError)
+            if !ok {
+                panic(e)
+            }
+            p.record(perr)
+            /*
+             * The rest of a block that failed in its header.
+             * skipBlock counts from inside the block, so step
+             * over the opener if the error was on it.
+             */
+            if !done && opensBlock(opener) {
+                if p.pos == start {
+                    p.nextToken()
+                }
+                p.skipBlock()
+            }
+            p.synchronize(start)
+            node = nil
+        }
+    }()
+    node = parse()
+    done = true
+    trailer()
+
+    return node
+}
+
+func opensBlock(tokenType int) bool {
+    switch tokenType {
+        case token.CLASS, token.DEF, token.IF, token.WHILE,
+             token.FOR:
+            return true
     }
-    buf.WriteString(message)
-    buf.WriteString("\n   ")
-    buf.WriteString(p.current.Line)
+    return false
+}
 
-    panic(buf.String())
+/*
+ * Skip to the 'end' that closes the current block,
+ * stepping over the blocks nested in it. Running out
+ * of input first is an error of its own.
+ */
+func (p *Parser) skipBlock() {
+    depth := 1
+    for p.peekCurrent() != token.EOF {
+        tokenType := p.peekCurrent()
+        p.nextToken()
+        if opensBlock(tokenType) {
+            depth++
+        } else if tokenType == token.END {
+            depth--
+            if depth == 0 {
+                return
+            }
+        }
+    }
+    p.record(p.newError("expected 'end' to close block"))
+}
+
+/*
+ * Skip past the next newline or ';'. Stops in front of
+ * the keywords that end a block, unless that is where
+ * the statement started, so the enclosing block can
+ * still be closed.
+ */
+func (p *Parser) synchronize(start int) {
+    for {
+        switch p.peekCurrent() {
+            case token.EOF:
+                return
+            case token.NEWLINE, token.SEMICOLON:
+                p.nextAndSkipNL()
+                return
+            case token.END, token.ELIF, token.ELSE:
+                if p.pos > start {
+                    return
+                }
+        }
+        p.nextToken()
+    }
 }
 
 func (p *Parser) matchToken(TokenType int,
@@ -98,6 +261,7 @@ func (p *Parser) matchToken(TokenType int,
 }
 
 func (p *Parser) nextToken() {
+    p.pos++
     p.current = p.next
     p.next = p.scanner.nextToken()
 }
@@ -146,19 +310,19 @@ func (p *Parser) stmtTrailer() {
 
 func (p *Parser) Program(node *interm.Node) *interm.Node {
     p.skipNL()
-    tokenType := p.peekCurrent()
-
-    for tokenType != token.EOF {
-        switch tokenType {
-            case token.CLASS:
-                node.Add(p.classStmt())
-            case token.DEF:
-                node.Add(p.defStmt())
-            default:
-                node.Add(p.stmt())
+    for p.peekCurrent() != token.EOF {
+        n := p.parseStmt(func() *interm.Node {
+            switch p.peekCurrent() {
+                case token.CLASS:
+                    return p.classStmt()
+                case token.DEF:
+                    return p.defStmt()
+            }
+            return p.stmt()
+        }, p.stmtTrailer)
+        if n != nil {
+            node.Add(n)
         }
-        p.stmtTrailer()
-        tokenType = p.peekCurrent()
     }
     return node
 }
@@ -231,19 +395,18 @@ func (p *Parser) classBlock() *interm.Node {
 
     tokenType := p.peekCurrent()
     for tokenType != token.END && tokenType != token.EOF {
-        if tokenType == token.DEF {
-            root.Add(p.defStmt())
-        } else {
-            root.Add(p.stmt())
-        }
-        tokenType = p.peekCurrent()
-        if tokenType == token.SEMICOLON {
-            p.nextAndSkipNL()
-        } else {
-            p.matchNewline("expected newline")
+        n := p.parseStmt(func() *interm.Node {
+            if p.peekCurrent() == token.DEF {
+                return p.defStmt()
+            }
+            return p.stmt()
+        }, p.blockTrailer)
+        if n != nil {
+            root.Add(n)
         }
         tokenType = p.peekCurrent()
     }
+    p.closeNode(root)
     p.matchToken(token.END, "expected 'end' to close class")
 
     return root
@@ -275,6 +438,7 @@ func (p *Parser) defStmt() *interm.Node {
     }
     p.matchNewline("expected newline")
     root.Add(p.stmtBlock())
+    p.closeNode(root)
     p.matchToken(token.END, "expected 'end' to close function")
 
     return root
@@ -322,16 +486,21 @@ func (p *Parser) stmtBlock() *interm.Node {
     p.skipNL()
 
     for !p.blockFollows() {
-        root.Add(p.stmt())
-        if p.peekCurrent() == token.SEMICOLON {
-            p.nextAndSkipNL()
-        } else {
-            p.matchNewline("expected newline")
+        if n := p.parseStmt(p.stmt, p.blockTrailer); n != nil {
+            root.Add(n)
         }
     }
     return root
 }
 
+func (p *Parser) blockTrailer() {
+    if p.peekCurrent() == token.SEMICOLON {
+        p.nextAndSkipNL()
+    } else {
+        p.matchNewline("expected newline")
+    }
+}
+
 /*
  * This is now augmented to take an arbitrary
  * amount of expressions to evaluate on runtime
@@ -354,6 +523,7 @@ func (p *Parser) controlStmt() *interm.Node {
     p.matchToken(token.DO, "expected 'do' to open block")
     root.Add(p.stmtBlock())
     
+    p.closeNode(root)
     p.matchToken(token.END, "expected 'end' to close block")
 
     return root
@@ -374,6 +544,7 @@ func (p *Parser) forStmt() *interm.Node {
     root.Add(p.expr())
     p.matchToken(token.DO, "expected 'do' to open block")
     root.Add(p.stmtBlock())
+    p.closeNode(root)
     p.matchToken(token.END, "expected 'end' to close block")
 
     return root
@@ -402,6 +573,7 @@ func (p *Parser) ifStmt() *interm.Node {
         p.nextToken()
         root.Add(p.stmtBlock())
     }
+    p.closeNode(root)
     p.matchToken(token.END, "expected 'end' to close block")
 
     return root
@@ -528,15 +700,24 @@ func (p *Parser) checkLHS(tokenType int) {
 }
 
 func (p *Parser) expr() *interm.Node {
-    return p.rangeExpr()
+    return p.orExpr()
 }
 
+/*
+ * Ranges are 'a..b', or 'a..=b' to include b, followed
+ * by an optional '..step'. The node's Str tells the two
+ * forms apart. Either end of '..' can be left out, so
+ * 'a....-1' counts down from a. They bind harder than
+ * comparisons, 'x in 1..10' looks x up in the range.
+ */
 func (p *Parser) rangeExpr() *interm.Node {
     var root *interm.Node
-    if p.peekCurrent() != token.DOTDOT {
-        root = p.orExpr()
+    tokenType := p.peekCurrent()
+    if tokenType != token.DOTDOT && tokenType != token.DOTDOTEQ {
+        root = p.bitwiseOrExpr()
     }
-    if p.peekCurrent() == token.DOTDOT {
+    tokenType = p.peekCurrent()
+    if tokenType == token.DOTDOT || tokenType == token.DOTDOTEQ {
         opNode := p.createNode(p.current.Str, token.RANGE)
         if root == nil {
             root = opNode
@@ -545,21 +726,34 @@ func (p *Parser) rangeExpr() *interm.Node {
             root.Flags |= interm.FLAG_RANGELHS
         }
         p.nextToken()
-        tokenType := p.peekCurrent()
-        /*
-         * This is a quick fix only, an empty '..' can be used
-         * infront of the tokens defined below.
-         */
-        if tokenType != token.EOF && tokenType != token.SEMICOLON &&
-           tokenType != token.RBRACK && tokenType != token.COMMA &&
-           tokenType != token.DO && tokenType != token.RPAREN {
-            root.Add(p.orExpr())
+        // The end is left out if no expression follows.
+        if p.atExprStart() {
+            root.Add(p.bitwiseOrExpr())
             root.Flags |= interm.FLAG_RANGERHS
+        } else if root.Str == "..=" {
+            p.postError("expected end of inclusive range")
+        }
+        if p.peekCurrent() == token.DOTDOT {
+            p.nextToken()
+            root.Add(p.bitwiseOrExpr())
+            root.Flags |= interm.FLAG_RANGESTEP
         }
     }
     return root
 }
 
+// Whether an expression can start at the current token.
+func (p *Parser) atExprStart() bool {
+    tokenType := p.peekCurrent()
+    switch tokenType {
+        case token.NAME, token.LBRACK, token.LBRACE, token.LPAREN,
+             token.NEW, token.PRINT:
+            return true
+    }
+    return (tokenType >= token.STRING && tokenType <= token.NIL ||
+            p.isFactor())
+}
+
 func (p *Parser) orExpr() *interm.Node {
     root := p.andExpr()
     for p.peekCurrent() == token.PIPEPIPE {
@@ -608,13 +802,33 @@ func (p *Parser) equalExpr() *interm.Node {
     return root
 }
 
-func (p *Parser) compExpr() *interm.Node {
-    root := p.bitwiseOrExpr()
+/*
+ * 'not' and 'is' are not keywords, they are only taken
+ * as ones in 'not in', 'is' and 'is not'.
+ */
+func (p *Parser) atNot() bool {
+    return p.peekCurrent() == token.NAME && p.current.Str == "not"
+}
+
+func (p *Parser) atIs() bool {
+    return p.peekCurrent() == token.NAME && p.current.Str == "is"
+}
+
+func (p *Parser) isCompOp() bool {
     tokenType := p.peekCurrent()
+    if tokenType >= token.LT && tokenType <= token.GTEQ {
+        return true
+    }
+    return (tokenType == token.IN || p.atIs() ||
+            p.atNot() && p.peekNext() == token.IN)
+}
+
+func (p *Parser) compExpr() *interm.Node {
+    root := p.rangeExpr()
 
-    for tokenType >= token.LT && tokenType <= token.GTEQ {
+    for p.isCompOp() {
         var opNode *interm.Node
-        switch tokenType {
+        switch p.peekCurrent() {
             case token.LT:
                 opNode = p.createNode(p.current.Str, token.LT)
             case token.LTEQ:
@@ -623,16 +837,29 @@ func (p *Parser) compExpr() *interm.Node {
                 opNode = p.createNode(p.current.Str, token.GT)
             case token.GTEQ:
                 opNode = p.createNode(p.current.Str, token.GE)
+            case token.IN:
+                opNode = p.createNode(p.current.Str, token.IN)
+            case token.NAME:
+                if p.atIs() {
+                    opNode = p.createNode(p.current.Str, token.IS)
+                    if p.peekNext() != token.NAME || p.next.Str != "not" {
+                        break
+                    }
+                    opNode.Str, opNode.NodeType = "is not", token.IS_NOT
+                } else {
+                    opNode = p.createNode("not in", token.NOT_IN)
+                }
+                p.nextToken()
+                opNode.Extend(p.current.LineNum, p.current.Col,
+                              p.current.EndCol)
         }
         root = root.GiveRootTo(opNode)
 
         p.nextToken()
-        root.Add(p.bitwiseOrExpr())
+        root.Add(p.rangeExpr())
 
         compNode := p.createNode("COMP_OP", token.COMP_OP)
         root = root.GiveRootTo(compNode)
-        
-        tokenType = p.peekCurrent()
     }
     return root
 }
@@ -679,7 +906,7 @@ func (p *Parser) bitwiseShiftExpr() *interm.Node {
 
     for tokenType == token.LEFTSHIFT ||
         tokenType == token.RIGHTSHIFT {
-        opNode := p.createNode(p.current.Str, token.LEFTSHIFT)
+        opNode := p.createNode(p.current.Str, tokenType)
         root = root.GiveRootTo(opNode)
 
         p.nextToken()
@@ -720,6 +947,8 @@ func (p *Parser) termExpr() *interm.Node {
                 opNode = p.createNode(p.current.Str, token.MUL)
             case token.SLASH:
                 opNode = p.createNode(p.current.Str, token.DIV)
+            case token.SLASHSLASH:
+                opNode = p.createNode(p.current.Str, token.FLOORDIV)
             case token.PERCENT:
                 opNode = p.createNode(p.current.Str, token.MODULO)
         }
@@ -748,11 +977,28 @@ func (p *Parser) factorExpr() *interm.Node {
         if p.isFactor() {
             root.Add(p.factorExpr())
         } else {
-            root.Add(p.trailerExpr())
+            root.Add(p.powerExpr())
         }
         return root
     }
-    return p.trailerExpr()
+    return p.powerExpr()
+}
+
+/*
+ * '**' binds harder than the unary operators on its left
+ * but not on its right, and groups to the right. So
+ * '-2 ** 2' is -4 and '2 ** 3 ** 2' is 2 ** 9.
+ */
+func (p *Parser) powerExpr() *interm.Node {
+    root := p.trailerExpr()
+    if p.peekCurrent() == token.STARSTAR {
+        opNode := p.createNode(p.current.Str, token.POWER)
+        root = root.GiveRootTo(opNode)
+
+        p.nextToken()
+        root.Add(p.factorExpr())
+    }
+    return root
 }
 
 func (p *Parser) trailerExpr() *interm.Node {
@@ -777,7 +1023,9 @@ func (p *Parser) atom() *interm.Node {
     var node *interm.Node
     tokenType := p.peekCurrent()
 
-    if tokenType >= token.STRING && tokenType <= token.NIL ||
+    if tokenType == token.STRING {
+        node = p.stringLiteral()
+    } else if tokenType >= token.STRING && tokenType <= token.NIL ||
        tokenType == token.NAME {
         node = p.createNode(p.current.Str, p.current.TokenType)
         p.nextToken()
@@ -786,9 +1034,7 @@ func (p *Parser) atom() *interm.Node {
     } else if tokenType == token.LBRACE {
         node = p.hashLiteral()
     } else if tokenType == token.LPAREN {
-        p.nextToken()
-        node = p.expr()
-        p.matchToken(token.RPAREN, "expected ')' to close group")
+        node = p.group()
     } else if tokenType == token.NEW {
         node = p.newStmt()
     } else if tokenType == token.PRINT {
@@ -801,16 +1047,157 @@ func (p *Parser) atom() *interm.Node {
     return node
 }
 
+/*
+ * A string literal. One with '#{expr}' in it becomes an
+ * INTERP node, holding the literal as its Str, with the
+ * text around the interpolations as STRING children and
+ * the interpolated expressions in between. Raw strings
+ * are taken as they are.
+ */
+func (p *Parser) stringLiteral() *interm.Node {
+    tok := p.current
+    root := p.createNode(tok.Str, token.STRING)
+    p.nextToken()
+    if tok.Str[0] == 'r' || tok.Str[0] == 'R' {
+        return root
+    }
+    quote := tok.Str[:1]
+    last := 1
+    for i := 1; i < len(tok.Str) - 1; i++ {
+        if tok.Str[i] == '\\' {
+            i++
+            continue
+        }
+        if tok.Str[i] != '#' || tok.Str[i + 1] != '{' {
+            continue
+        }
+        end := interpolationEnd(tok.Str, i + 2)
+        if end == -1 {
+            // The scanner has reported the string as unterminated.
+            return root
+        }
+        root.NodeType = token.INTERP
+        if i > last {
+            part := p.createNode(quote + tok.Str[last:i] + quote,
+                                 token.STRING)
+            root.Add(part)
+        }
+        root.Add(p.interpolatedExpr(tok, i + 2, end))
+        last, i = end + 1, end
+    }
+    if root.NodeType == token.INTERP && last < len(tok.Str) - 1 {
+        part := p.createNode(quote + tok.Str[last:len(tok.Str) - 1] +
+                             quote, token.STRING)
+        root.Add(part)
+    }
+    return root
+}
+
+/*
+ * Parse the expression at tok.Str[start:end] of a string
+ * literal with a parser of its own. It is given the text
+ * at the columns it has in the source, so the nodes and
+ * errors line up with the literal.
+ */
+func (p *Parser) interpolatedExpr(tok token.Token,
+                                  start, end int) (node *interm.Node) {
+    lineNum, col := tok.LineNum, tok.Col + start
+    line := tok.Line
+    if nl := strings.LastIndexByte(tok.Str[:start], '\n'); nl != -1 {
+        // The string runs over more lines than the token's.
+        lineNum += strings.Count(tok.Str[:start], "\n")
+        col, line = start - nl, ""
+    }
+    sub := newParser(strings.Repeat(" ", col - 1) +
+                     tok.Str[start:end], p.pathname)
+    defer func() {
+        if e := recover(); e != nil {
+            perr, ok := e.(*ParseError)
+            if !ok {
+                panic(e)
+            }
+            perr.LineNum, perr.Incomplete = lineNum, false
+            if line != "" {
+                perr.Line = line
+            }
+            panic(perr)
+        }
+    }()
+    if sub.peekCurrent() == token.EOF {
+        sub.postError("expected expression in interpolation")
+    }
+    node = sub.expr()
+    if sub.peekCurrent() != token.EOF {
+        sub.postError("expected '}' to close interpolation")
+    }
+    if len(sub.scanner.errors) > 0 {
+        panic(sub.scanner.errors[0])
+    }
+    moveNodes(node, lineNum - 1, line)
+    return node
+}
+
+// Move the nodes of a tree 'lines' lines down.
+func moveNodes(node *interm.Node, lines int, line string) {
+    node.LineNum += lines
+    if node.EndLineNum != 0 {
+        node.EndLineNum += lines
+    }
+    if line != "" {
+        node.Line = line
+    }
+    for _, child := range node.Children {
+        moveNodes(child, lines, line)
+    }
+}
+
+/*
+ * A parenthesized expression, or a tuple literal if the
+ * expression is followed by a comma. '()' is the empty
+ * tuple and '(a,)' a tuple of one.
+ */
+func (p *Parser) group() *interm.Node {
+    root := p.createNode("TUPLE", token.TUPLE)
+    p.nextToken()
+    if p.peekCurrent() == token.RPAREN {
+        p.closeNode(root)
+        p.nextToken()
+        return root
+    }
+    node := p.expr()
+    if p.peekCurrent() != token.COMMA {
+        p.matchToken(token.RPAREN, "expected ')' to close group")
+        return node
+    }
+    root.Add(node)
+    for p.peekCurrent() == token.COMMA {
+        p.nextAndSkipNL()
+        if p.peekCurrent() == token.RPAREN {
+            break
+        }
+        root.Add(p.expr())
+        p.skipNL()
+    }
+    p.closeNode(root)
+    p.matchToken(token.RPAREN, "expected ')' to close tuple")
+    return root
+}
+
 func (p *Parser) arrayLiteral() *interm.Node {
     root := p.createNode("LIST", token.LIST)
     p.nextAndSkipNL()
     p.expressionList(root, token.RBRACK)
     p.skipNL()
+    p.closeNode(root)
     p.matchToken(token.RBRACK, "expected ']' to close array" +
                  " literal")
     return root
 }
 
+/*
+ * A hash literal, or a set literal if the first element
+ * isn't followed by '=>'. '{}' is an empty hash.
+ */
 func (p *Parser) hashLiteral() *interm.Node {
     root := p.createNode("HASH", token.HASH)
     if p.peekNext() == token.RBRACE {
@@ -818,24 +1205,37 @@ func (p *Parser) hashLiteral() *interm.Node {
         p.nextToken()
         return root
     }
-    for true {
+    for first := true; ; first = false {
         hashElem := p.createNode("HASH_ELEM", token.HASH_ELEM)
         p.nextAndSkipNL()
 
-        hashElem.Add(p.expr())
-        p.skipNL()
-        p.matchToken(token.EQGT, "expected '=>' between key" +
-                     " and value")
+        elem := p.expr()
         p.skipNL()
-        hashElem.Add(p.expr())
-        root.Add(hashElem)
-
+        if first && p.peekCurrent() != token.EQGT {
+            root.Str, root.NodeType = "SET", token.SET
+        }
+        if root.NodeType == token.SET {
+            root.Add(elem)
+        } else {
+            hashElem.Add(elem)
+            p.matchToken(token.EQGT, "expected '=>' between key" +
+                         " and value")
+            p.skipNL()
+            hashElem.Add(p.expr())
+            root.Add(hashElem)
+        }
         p.skipNL()
         if p.peekCurrent() != token.COMMA {
             break
         }
     }
-    p.matchToken(token.RBRACE, "expected '}' to close hash literal")
+    p.closeNode(root)
+    if root.NodeType == token.SET {
+        p.matchToken(token.RBRACE, "expected '}' to close set literal")
+    } else {
+        p.matchToken(token.RBRACE, "expected '}' to close hash" +
+                     " literal")
+    }
     return root
 }
 
@@ -856,6 +1256,7 @@ func (p *Parser) newStmt() *interm.Node {
     p.expressionList(argsNode, token.RPAREN)
     root.Add(argsNode)
 
+    p.closeNode(root)
     p.matchToken(token.RPAREN, "expected ')'")
     return root
 }
@@ -867,6 +1268,7 @@ func (p *Parser) subscript(node *interm.Node) *interm.Node {
     p.nextAndSkipNL()
     root.Add(p.expr())
     p.skipNL()
+    p.closeNode(root)
     p.matchToken(token.RBRACK, "expected ']' to close subscript")
     return root
 }
@@ -879,13 +1281,52 @@ func (p *Parser) callFunction(node *interm.Node) *interm.Node {
     argsNode := p.createNode("ARGUMENTS", token.ARGUMENTS)
     root.Add(argsNode)
 
-    p.expressionList(argsNode, token.RPAREN)
+    p.argumentList(argsNode)
     p.skipNL()
+    p.closeNode(root)
     p.matchToken(token.RPAREN, "expected ')' to close func call")
 
     return root
 }
 
+/*
+ * Like expressionList, where arguments can be passed by
+ * keyword as 'name = expr'. Keyword arguments become KWARG
+ * nodes and have to come after the positional ones.
+ */
+func (p *Parser) argumentList(node *interm.Node) {
+    if p.peekCurrent() == token.RPAREN {
+        return
+    }
+    keywords := make(map[string]bool)
+    for true {
+        if p.peekCurrent() == token.NAME && p.peekNext() == token.EQ {
+            name := p.current.Str
+            if keywords[name] {
+                p.postError("keyword argument '" + name + "' repeated")
+            }
+            keywords[name] = true
+            kwNode := p.createNode(name, token.KWARG)
+            p.nextToken()
+            p.nextAndSkipNL()
+            kwNode.Add(p.expr())
+            node.Add(kwNode)
+        } else {
+            if len(keywords) > 0 {
+                p.postError("positional argument follows keyword" +
+                            " argument")
+            }
+            node.Add(p.expr())
+        }
+        p.skipNL()
+
+        if p.peekCurrent() != token.COMMA {
+            break
+        }
+        p.nextAndSkipNL()
+    }
+}
+
 func (p *Parser) instanceAttr(node *interm.Node) *interm.Node {
     root := p.createNode(p.current.Str, token.MEMBER)
     root = node.GiveRootTo(root)
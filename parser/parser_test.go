@@ -0,0 +1,107 @@
+package parser
+
+import (
+    "testing"
+    "github.com/Magnus9/blue/token"
+    "github.com/Magnus9/blue/interm"
+)
+
+func parseStmt(t *testing.T, src string) *interm.Node {
+    root, err := ParseFromRepl("test.bl", src)
+    if err != nil {
+        t.Fatalf("%q: %v", src, err)
+    }
+    if root.Nchildren != 1 {
+        t.Fatalf("%q: parsed into %d statements", src, root.Nchildren)
+    }
+    return root.Children[0]
+}
+
+// A range is the operand of a comparison, not the other way round.
+func TestRangeInComparison(t *testing.T) {
+    node := parseStmt(t, "x in 1..10\n")
+    if node.NodeType != token.COMP_OP {
+        t.Fatalf("got node type %d, want COMP_OP", node.NodeType)
+    }
+    op := node.Children[0]
+    if op.NodeType != token.IN {
+        t.Fatalf("got operator %q, want 'in'", op.Str)
+    }
+    if rng := op.Children[1]; rng.NodeType != token.RANGE {
+        t.Errorf("right operand has node type %d, want RANGE",
+                 rng.NodeType)
+    }
+}
+
+// The ends of a range take additive expressions whole.
+func TestRangeInFor(t *testing.T) {
+    node := parseStmt(t, "for i in a..b+1 do\nend\n")
+    if node.NodeType != token.FOR {
+        t.Fatalf("got node type %d, want FOR", node.NodeType)
+    }
+    rng := node.Children[1]
+    if rng.NodeType != token.RANGE || rng.Nchildren != 2 {
+        t.Fatalf("got node type %d with %d children, want a RANGE" +
+                 " with 2", rng.NodeType, rng.Nchildren)
+    }
+    if end := rng.Children[1]; end.NodeType != token.ADD {
+        t.Errorf("end of range has node type %d, want ADD",
+                 end.NodeType)
+    }
+}
+
+// An error in a nested block header is skipped along with
+// that block only, leaving the errors after it alone.
+func TestRecoverNestedBlock(t *testing.T) {
+    src := "def f()\n" +
+           "    for i in 1..3 do\n" +
+           "        def g()\n" +
+           "            return 1\n" +
+           "        end\n" +
+           "    end\n" +
+           "end\n" +
+           "class B\n" +
+           "    x = )\n" +
+           "end\n"
+    _, err := ParseFromRepl("test.bl", src)
+    errors, ok := err.(ErrorList)
+    if !ok {
+        t.Fatalf("got %v, want an ErrorList", err)
+    }
+    lines := []int{3, 9}
+    if len(errors) != len(lines) {
+        t.Fatalf("got %d errors, want %d:\n%v", len(errors),
+                 len(lines), errors)
+    }
+    for i, e := range errors {
+        if e.LineNum != lines[i] {
+            t.Errorf("error %d is on line %d, want %d", i, e.LineNum,
+                     lines[i])
+        }
+    }
+}
+
+// 'print' is an expression, so it doesn't end an open bracket.
+func TestPrintInBrackets(t *testing.T) {
+    node := parseStmt(t, "x = (1 +\nprint 2)\n")
+    if node.NodeType != token.ASSIGN {
+        t.Fatalf("got node type %d, want ASSIGN", node.NodeType)
+    }
+}
+
+// 'is' is only an operator between two operands.
+func TestIsAsName(t *testing.T) {
+    node := parseStmt(t, "is = 1\n")
+    if node.NodeType != token.ASSIGN {
+        t.Fatalf("got node type %d, want ASSIGN", node.NodeType)
+    }
+    node = parseStmt(t, "a is not is\n")
+    if node.NodeType != token.COMP_OP {
+        t.Fatalf("got node type %d, want COMP_OP", node.NodeType)
+    }
+    op := node.Children[0]
+    if op.NodeType != token.IS_NOT || op.Children[1].Str != "is" {
+        t.Errorf("got %q with right operand %q, want 'is not' with" +
+                 " 'is'", op.Str, op.Children[1].Str)
+    }
+}
@@ -2,8 +2,8 @@
 package parser
 
 import (
-    "fmt"
     "bytes"
+    "strings"
     "github.com/Magnus9/blue/token"
 )
 
@@ -16,6 +16,14 @@ type Scanner struct {
     charPointer   byte
     sourcePos     int
     lineNum       int
+    // Position in sourceProgram where the current line starts.
+    lineStart     int
+    // Set once a token has been made on the current line.
+    lineHasToken  bool
+    // Number of brackets open, newlines inside them are skipped.
+    depth         int
+    comments      []token.Comment
+    errors        ErrorList
 }
 
 func newScanner(sourceProgram, pathname string) Scanner {
@@ -33,15 +41,103 @@ func newScanner(sourceProgram, pathname string) Scanner {
     return scanner
 }
 
+/*
+ * Split a program into its tokens, EOF left out. Errors
+ * are ignored, the tokens are whatever could be made of
+ * the input. Used for syntax highlighting.
+ */
+func Tokenize(program string) []token.Token {
+    s := newScanner(program, "")
+    var tokens []token.Token
+    for {
+        tok := s.nextToken()
+        if tok.TokenType == token.EOF {
+            return tokens
+        }
+        tokens = append(tokens, tok)
+    }
+}
+
+/*
+ * Record an error at the current character. The scanner
+ * carries on after it, so the parser gets to see the rest
+ * of the program.
+ */
 func (s *Scanner) postError(message string) {
-    buf := fmt.Sprintf("%s:%d => %s\n   %s", s.pathname,
-                       s.lineNum, message,
-                       s.lineBuf.String())
-    panic(buf)
+    s.postErrorAt(s.sourcePos, message)
+}
+
+func (s *Scanner) postErrorAt(pos int, message string) {
+    col := pos - s.lineStart + 1
+    s.errors = append(s.errors, &ParseError{
+        Pathname: s.pathname,
+        LineNum : s.lineNum,
+        Col     : col,
+        EndCol  : col + 1,
+        Message : message,
+        Line    : s.lineBuf.String(),
+    })
+}
+
+func (s *Scanner) openBracket(str string, ttype int) token.Token {
+    s.depth++
+    return s.makeSymToken(str, ttype)
+}
+
+func (s *Scanner) closeBracket(str string, ttype int) token.Token {
+    if s.depth > 0 {
+        s.depth--
+    }
+    return s.makeSymToken(str, ttype)
+}
+
+// Keywords that only ever start a statement.
+var stmtKeywords = map[string]struct{}{
+    "def"     : struct{}{},
+    "class"   : struct{}{},
+    "if"      : struct{}{},
+    "elif"    : struct{}{},
+    "else"    : struct{}{},
+    "end"     : struct{}{},
+    "for"     : struct{}{},
+    "while"   : struct{}{},
+    "switch"  : struct{}{},
+    "case"    : struct{}{},
+    "default" : struct{}{},
+    "return"  : struct{}{},
+    "continue": struct{}{},
+    "break"   : struct{}{},
+    "import"  : struct{}{},
+    "from"    : struct{}{},
+}
+
+/*
+ * Report whether the line in lineBuf starts with a
+ * statement keyword. A newline inside brackets is only
+ * skipped if it is not, so a bracket that is never
+ * closed doesn't swallow the rest of the program.
+ */
+func (s *Scanner) lineStartsStmt() bool {
+    line := strings.TrimLeft(s.lineBuf.String(), " \t\r")
+    end := strings.IndexFunc(line, func(r rune) bool {
+        return !(r == '_' || r >= 'a' && r <= 'z' ||
+                 r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+    })
+    if end >= 0 {
+        line = line[:end]
+    }
+    _, ok := stmtKeywords[line]
+    return ok
+}
+
+// Mark the last error as one that more input could fix.
+func (s *Scanner) incomplete() {
+    s.errors[len(s.errors) - 1].Incomplete = true
 }
 
 func (s *Scanner) makeSymToken(str string,
                                ttype int) token.Token {
+    start := s.sourcePos
     switch len(str) {
         case 1:
             s.nextCharx(1)
@@ -50,28 +146,50 @@ func (s *Scanner) makeSymToken(str string,
         case 3:
             s.nextCharx(3)
     }
-    return token.New(str, s.lineBuf.String(),
-                     ttype, s.lineNum)
+    s.lineHasToken = true
+    return s.newToken(str, ttype, start, s.sourcePos)
 }
 
+/*
+ * Make a token out of the characters just read. A newline
+ * is made before it is read.
+ */
 func (s *Scanner) makeToken(str string,
                             ttype int) token.Token {
-    return token.New(str, s.lineBuf.String(),
-                     ttype, s.lineNum)
+    start, end := s.sourcePos - len(str), s.sourcePos
+    if ttype == token.NEWLINE {
+        start, end = s.sourcePos, s.sourcePos + 1
+    }
+    s.lineHasToken = true
+    return s.newToken(str, ttype, start, end)
 }
 
+// A token covering sourceProgram[start:end].
+func (s *Scanner) newToken(str string, ttype,
+                           start, end int) token.Token {
+    col := start - s.lineStart + 1
+    return token.New(str, s.lineBuf.String(), ttype,
+                     s.lineNum, col, col + end - start)
+}
+
+func (s *Scanner) addComment(text string, lineNum int,
+                             trailing, long bool) {
+    s.comments = append(s.comments, token.Comment{
+        Text      : text,
+        LineNum   : lineNum,
+        EndLineNum: s.lineNum,
+        Trailing  : trailing,
+        Long      : long,
+    })
+}
+
+/*
+ * Read the line that starts after sourcePos into lineBuf,
+ * indentation included so columns can be counted on it.
+ */
 func (s *Scanner) readLine() {
     s.lineBuf.Reset()
-    var i int
-    // First for loop removes leading whitespace.
-    for i = s.sourcePos + 1; i < len(s.sourceProgram);
-        i++ {
-        ch := s.sourceProgram[i]
-        if ch != ' ' && ch != '\t' {
-            break
-        }
-    }
-    for ; i < len(s.sourceProgram); i++ {
+    for i := s.sourcePos + 1; i < len(s.sourceProgram); i++ {
         ch := s.sourceProgram[i]
         if ch == '\n' {
             break
@@ -81,7 +199,10 @@ func (s *Scanner) readLine() {
 }
 
 func (s *Scanner) nextChar() byte {
-    s.sourcePos++
+    // Never move past the end, the position is sliced with.
+    if s.sourcePos < len(s.sourceProgram) {
+        s.sourcePos++
+    }
     if s.sourcePos >= len(s.sourceProgram) {
         s.charPointer = EOF
     } else {
@@ -117,12 +238,12 @@ func (s *Scanner) nextToken() token.Token {
         switch s.charPointer {
             // List of one-character symbols.
             case '~': return s.makeSymToken("~", token.TILDE)
-            case '(': return s.makeSymToken("(", token.LPAREN)
-            case ')': return s.makeSymToken(")", token.RPAREN)
-            case '[': return s.makeSymToken("[", token.LBRACK)
-            case ']': return s.makeSymToken("]", token.RBRACK)
-            case '{': return s.makeSymToken("{", token.LBRACE)
-            case '}': return s.makeSymToken("}", token.RBRACE)
+            case '(': return s.openBracket("(", token.LPAREN)
+            case ')': return s.closeBracket(")", token.RPAREN)
+            case '[': return s.openBracket("[", token.LBRACK)
+            case ']': return s.closeBracket("]", token.RBRACK)
+            case '{': return s.openBracket("{", token.LBRACE)
+            case '}': return s.closeBracket("}", token.RBRACE)
             case ',': return s.makeSymToken(",", token.COMMA)
             case ';': return s.makeSymToken(";", token.SEMICOLON)
             case ':': return s.makeSymToken("(", token.COLON)
@@ -130,8 +251,17 @@ func (s *Scanner) nextToken() token.Token {
                 token := s.makeToken("NL", token.NEWLINE)
                 s.readLine()
                 s.nextChar()
+                s.lineStart = s.sourcePos
 
                 s.lineNum++
+                s.lineHasToken = false
+                if s.depth > 0 {
+                    if !s.lineStartsStmt() {
+                        continue
+                    }
+                    // The bracket was never closed, let the parser say so.
+                    s.depth = 0
+                }
                 return token
             case ' ':
                 for s.charPointer == ' ' || s.charPointer == '\r' ||
@@ -140,9 +270,12 @@ func (s *Scanner) nextToken() token.Token {
                 }
                 continue
             case '#':
+                pos := s.sourcePos
                 for s.charPointer != '\n' && s.charPointer != EOF {
                     s.nextChar()
                 }
+                s.addComment(strings.TrimRight(s.getSlice(pos), " \t\r"),
+                             s.lineNum, s.lineHasToken, false)
             case '0':
                 nextChar := s.peekChar(1)
                 if nextChar == 'X' || nextChar == 'x' {
@@ -157,12 +290,24 @@ func (s *Scanner) nextToken() token.Token {
                 if s.peekChar(1) != '\n' {
                     s.postError("missing newline after line-continuation" +
                                 " character")
+                    if s.peekChar(1) == EOF {
+                        s.incomplete()
+                    }
+                    s.nextChar()
+                    continue
                 }
-                s.nextCharx(2)
+                s.nextChar()
+                s.readLine()
+                s.nextChar()
+                s.lineStart = s.sourcePos
+                s.lineNum++
 
             // List of two-character symbols.
             case '.':
                 if s.peekChar(1) == '.' {
+                    if s.peekChar(2) == '=' {
+                        return s.makeSymToken("..=", token.DOTDOTEQ)
+                    }
                     return s.makeSymToken("..", token.DOTDOT)
                 }
                 return s.makeSymToken(".", token.DOT)
@@ -177,11 +322,17 @@ func (s *Scanner) nextToken() token.Token {
                 }
                 return s.makeSymToken("-", token.MINUS)
             case '*':
+                if s.peekChar(1) == '*' {
+                    return s.makeSymToken("**", token.STARSTAR)
+                }
                 if s.peekChar(1) == '=' {
                     return s.makeSymToken("*=", token.STAREQ)
                 }
                 return s.makeSymToken("*", token.STAR)
             case '/':
+                if s.peekChar(1) == '/' {
+                    return s.makeSymToken("//", token.SLASHSLASH)
+                }
                 if s.peekChar(1) == '=' {
                     return s.makeSymToken("/=", token.SLASHEQ)
                 }
@@ -258,6 +409,7 @@ func (s *Scanner) nextToken() token.Token {
                 } else {
                     s.postError("unrecognized character '" +
                                  string(s.charPointer) + "'")
+                    s.nextChar()
                 }
 
         }
@@ -309,6 +461,7 @@ func (s *Scanner) parseWord() token.Token {
 func (s *Scanner) parseNumber() token.Token {
     pos := s.sourcePos
 
+    tokenType := token.INTEGER
     for (s.isDigit()) {
         s.nextChar()
     }
@@ -317,9 +470,34 @@ func (s *Scanner) parseNumber() token.Token {
         for (s.isDigit()) {
             s.nextChar()
         }
-        return s.makeToken(s.getSlice(pos), token.FLOAT)
+        tokenType = token.FLOAT
     }
-    return s.makeToken(s.getSlice(pos), token.INTEGER)
+    if n := s.exponentSize(); n > 0 {
+        s.nextCharx(n)
+        for (s.isDigit()) {
+            s.nextChar()
+        }
+        tokenType = token.FLOAT
+    }
+    return s.makeToken(s.getSlice(pos), tokenType)
+}
+
+/*
+ * The number of characters up to the digits of an
+ * exponent like 'e10' or 'E-5', 0 if there is none.
+ */
+func (s *Scanner) exponentSize() int {
+    if s.charPointer != 'e' && s.charPointer != 'E' {
+        return 0
+    }
+    n := 1
+    if ch := s.peekChar(1); ch == '+' || ch == '-' {
+        n++
+    }
+    if ch := s.peekChar(n); ch < '0' || ch > '9' {
+        return 0
+    }
+    return n
 }
 
 func (s *Scanner) parseHex() token.Token {
@@ -332,23 +510,80 @@ func (s *Scanner) parseHex() token.Token {
     return s.makeToken(s.getSlice(pos), token.INTEGER)
 }
 
+/*
+ * The position of the quote that closes the string whose
+ * opening quote is at 'pos', -1 if it isn't closed. The
+ * interpolations in it, see interpolationEnd, are skipped
+ * unless the string is raw.
+ */
+func stringEnd(str string, pos int, raw bool) int {
+    quote := str[pos]
+    for i := pos + 1; i < len(str); i++ {
+        switch {
+            case str[i] == '\\':
+                i++
+            case str[i] == quote:
+                return i
+            case !raw && str[i] == '#' && i + 1 < len(str) &&
+                 str[i + 1] == '{':
+                if i = interpolationEnd(str, i + 2); i == -1 {
+                    return -1
+                }
+        }
+    }
+    return -1
+}
+
+/*
+ * The position of the '}' that closes an interpolation
+ * whose expression starts at 'pos', -1 if it isn't closed.
+ * Braces and strings nested in the expression are skipped
+ * whole, so they may hold quotes and braces of their own.
+ */
+func interpolationEnd(str string, pos int) int {
+    depth := 1
+    for i := pos; i < len(str); i++ {
+        switch ch := str[i]; ch {
+            case '{':
+                depth++
+            case '}':
+                if depth--; depth == 0 {
+                    return i
+                }
+            case '"', '\'':
+                raw := i > 0 && (str[i - 1] == 'r' || str[i - 1] == 'R') &&
+                       (i < 2 || !isNameChar(str[i - 2]))
+                if i = stringEnd(str, i, raw); i == -1 {
+                    return -1
+                }
+        }
+    }
+    return -1
+}
+
+func isNameChar(ch byte) bool {
+    return (ch == '_' || ch >= 'a' && ch <= 'z' ||
+            ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9')
+}
+
 func (s *Scanner) parseString() token.Token {
     pos := s.sourcePos
 
+    raw := false
     if s.charPointer == 'R' || s.charPointer == 'r' {
+        raw = true
         s.nextChar()
     }
-    quote := s.charPointer
-    s.nextChar()
-
-    for (s.charPointer != quote && s.charPointer != EOF) {
-        if s.charPointer == '\\' {
-            s.nextChar()
-        }
+    end := stringEnd(s.sourceProgram, s.sourcePos, raw)
+    for s.sourcePos != end && s.charPointer != EOF {
         s.nextChar()
     }
     if s.charPointer == EOF {
-        s.postError("unterminated string literal")
+        s.postErrorAt(pos, "unterminated string literal")
+        // Mark the rest of the line.
+        s.errors[len(s.errors) - 1].EndCol = s.lineBuf.Len() + 1
+        s.incomplete()
+        return s.makeToken(s.getSlice(pos), token.STRING)
     }
     s.nextChar()
 
@@ -356,6 +591,8 @@ func (s *Scanner) parseString() token.Token {
 }
 
 func (s *Scanner) longComment() {
+    pos, lineNum := s.sourcePos, s.lineNum
+    trailing := s.lineHasToken
     s.nextCharx(3)
     for s.charPointer != EOF {
         if s.charPointer == '=' {
@@ -365,11 +602,17 @@ func (s *Scanner) longComment() {
         }
         if s.charPointer == '\n' {
             s.lineNum++
+            s.lineStart = s.sourcePos + 1
+            // The last line of the comment starts without code.
+            s.lineHasToken = false
         }
         s.nextChar()
     }
     if s.charPointer == EOF {
         s.postError("unterminated long comment")
+        s.incomplete()
+        return
     }
     s.nextCharx(3)
+    s.addComment(s.getSlice(pos), lineNum, trailing, true)
 }
\ No newline at end of file
@@ -0,0 +1,205 @@
+package repl
+
+import (
+    "os"
+    "fmt"
+    "sort"
+    "time"
+    "strings"
+    "io/ioutil"
+    "text/tabwriter"
+    "github.com/Magnus9/blue/blue"
+    "github.com/Magnus9/blue/parser"
+    "github.com/Magnus9/blue/interm"
+    "github.com/Magnus9/blue/objects"
+)
+
+type command struct {
+    args string
+    help string
+    run  func(arg string, globals map[string]objects.BlObject)
+}
+
+var commands map[string]*command
+
+func init() {
+    commands = map[string]*command{
+        "load" : {"file", "run a file into the session", cmdLoad},
+        "ast"  : {"stmt", "show the parse tree of a statement", cmdAst},
+        "type" : {"expr", "show the type of an expression", cmdType},
+        "time" : {"stmt", "run a statement and show how long it took",
+                  cmdTime},
+        "vars" : {"", "list the globals", cmdVars},
+        "reset": {"", "forget every global and imported module",
+                  cmdReset},
+        "save" : {"file", "write the statements run so far to a file",
+                  cmdSave},
+        "help" : {"", "list the commands", cmdHelp},
+    }
+}
+
+func commandNames() []string {
+    names := make([]string, 0, len(commands))
+    for name := range commands {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+/*
+ * Run a line of the form ':name arg'. The argument is
+ * the rest of the line, spaces and all.
+ */
+func runCommand(line string, globals map[string]objects.BlObject) {
+    line = strings.TrimSpace(line[1:])
+    name, arg := line, ""
+    if pos := strings.IndexAny(line, " \t"); pos >= 0 {
+        name, arg = line[:pos], strings.TrimSpace(line[pos + 1:])
+    }
+    cmd, ok := commands[name]
+    if !ok {
+        fmt.Fprintf(os.Stderr, "unknown command ':%s', try :help\n",
+                    name)
+        return
+    }
+    if cmd.args != "" && arg == "" {
+        fmt.Fprintf(os.Stderr, "usage: :%s %s\n", name, cmd.args)
+        return
+    }
+    cmd.run(arg, globals)
+}
+
+/*
+ * Read and parse a file, returning its source and tree.
+ * The tree is nil if that fails, a file that doesn't
+ * exist is skipped quietly if 'optional'.
+ */
+func parseFile(pathname string, optional bool) (string, *interm.Node) {
+    program, err := ioutil.ReadFile(pathname)
+    if err != nil {
+        if !(optional && os.IsNotExist(err)) {
+            fmt.Fprintf(os.Stderr, "%s\n", err)
+        }
+        return "", nil
+    }
+    ast, _, err := parser.ParseWithComments(pathname, string(program))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%s\n", err)
+        return "", nil
+    }
+    return string(program), ast
+}
+
+// Parse and run a file with 'globals' as its globals.
+func loadFile(pathname string, globals map[string]objects.BlObject,
+              optional bool) {
+    if _, ast := parseFile(pathname, optional); ast != nil {
+        blue.New(pathname, ast).Run(globals)
+    }
+}
+
+/*
+ * The source of the file goes into the session, as it
+ * was when loaded, so ':save' can reproduce it.
+ */
+func cmdLoad(arg string, globals map[string]objects.BlObject) {
+    program, ast := parseFile(arg, false)
+    if ast == nil {
+        return
+    }
+    session = append(session, program)
+    blue.New(arg, ast).Run(globals)
+}
+
+func cmdAst(arg string, globals map[string]objects.BlObject) {
+    ast, err := parser.ParseFromRepl("repl", arg)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%s\n", err)
+        return
+    }
+    for _, n := range ast.Children {
+        fmt.Println(n.ListTree())
+    }
+}
+
+func cmdType(arg string, globals map[string]objects.BlObject) {
+    ast, err := parser.ParseFromRepl("repl", arg)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%s\n", err)
+        return
+    }
+    obj := blue.New("repl", ast).RunValue(globals)
+    if obj == nil {
+        fmt.Fprintln(os.Stderr, "statement has no value")
+        return
+    }
+    fmt.Println(obj.BlType().Name)
+}
+
+func cmdTime(arg string, globals map[string]objects.BlObject) {
+    ast, err := parser.ParseFromRepl("repl", arg)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%s\n", err)
+        return
+    }
+    session = append(session, arg)
+    start := time.Now()
+    // Report the time even if the statement dies.
+    defer func() {
+        fmt.Printf("time: %v\n", time.Since(start))
+    }()
+    blue.New("repl", ast).Run(globals)
+}
+
+func cmdVars(arg string, globals map[string]objects.BlObject) {
+    names := make([]string, 0, len(globals))
+    for name := range globals {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+    for _, name := range names {
+        typeobj := globals[name].BlType()
+        repr := ""
+        if typeobj.Repr != nil {
+            repr = typeobj.Repr(globals[name]).Value
+        }
+        // Keep the listing to a line per global.
+        repr = strings.Replace(repr, "\n", "\\n", -1)
+        if len(repr) > 60 {
+            repr = repr[:57] + "..."
+        }
+        fmt.Fprintf(tw, "%s\t%s\t%s\n", name, typeobj.Name, repr)
+    }
+    tw.Flush()
+}
+
+func cmdReset(arg string, globals map[string]objects.BlObject) {
+    for name := range globals {
+        delete(globals, name)
+    }
+    blue.ResetModules()
+    session = nil
+}
+
+func cmdSave(arg string, globals map[string]objects.BlObject) {
+    var buf strings.Builder
+    for _, program := range session {
+        buf.WriteString(program)
+        buf.WriteByte('\n')
+    }
+    err := ioutil.WriteFile(arg, []byte(buf.String()), 0644)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "%s\n", err)
+    }
+}
+
+func cmdHelp(arg string, globals map[string]objects.BlObject) {
+    tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+    for _, name := range commandNames() {
+        cmd := commands[name]
+        fmt.Fprintf(tw, ":%s %s\t%s\n", name, cmd.args, cmd.help)
+    }
+    tw.Flush()
+}
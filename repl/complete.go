@@ -0,0 +1,105 @@
+package repl
+
+import (
+    "sort"
+    "strings"
+    "github.com/Magnus9/blue/blue"
+    "github.com/Magnus9/blue/token"
+    "github.com/Magnus9/blue/objects"
+)
+
+/*
+ * Completes the word in front of the cursor. A plain
+ * name completes to a global, builtin or keyword. A name
+ * after a '.' completes to a member of the live object
+ * the path before it evaluates to, i.e 'system.' gives
+ * the symbols of the system module. Commands complete
+ * after a leading ':'.
+ */
+type completer struct {
+    globals map[string]objects.BlObject
+}
+
+func isNameChar(ch rune) bool {
+    return (ch == '_' || ch >= 'a' && ch <= 'z' ||
+            ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9')
+}
+
+func (c *completer) Do(line []rune, pos int) ([][]rune, int) {
+    if len(line) > 0 && line[0] == ':' &&
+       !strings.ContainsAny(string(line[:pos]), " \t") {
+        return c.match(commandNames(), string(line[1:pos]))
+    }
+    start := pos
+    for start > 0 && (isNameChar(line[start - 1]) ||
+                      line[start - 1] == '.') {
+        start--
+    }
+    path := strings.Split(string(line[start:pos]), ".")
+    prefix := path[len(path) - 1]
+
+    var names []string
+    if len(path) == 1 {
+        names = c.names()
+    } else if obj := c.lookup(path[:len(path) - 1]); obj != nil {
+        names = objects.BlObjectMemberNames(obj)
+    }
+    return c.match(names, prefix)
+}
+
+// The rest of every name that starts with 'prefix'.
+func (c *completer) match(names []string,
+                          prefix string) ([][]rune, int) {
+    var candidates [][]rune
+    for _, name := range names {
+        if strings.HasPrefix(name, prefix) && name != prefix {
+            candidates = append(candidates,
+                                []rune(name[len(prefix):]))
+        }
+    }
+    return candidates, len([]rune(prefix))
+}
+
+// Every name that can start an expression or statement.
+func (c *completer) names() []string {
+    seen := make(map[string]struct{})
+    for name := range c.globals {
+        seen[name] = struct{}{}
+    }
+    for name := range blue.GetModuleMap()["builtins"].Locals {
+        seen[name] = struct{}{}
+    }
+    for name := range token.RES_WORDS {
+        seen[name] = struct{}{}
+    }
+    names := make([]string, 0, len(seen))
+    for name := range seen {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+/*
+ * Evaluate a path of member names, i.e 'a.b' in 'a.b.c'.
+ * Only members are looked up, nothing is ever called.
+ */
+func (c *completer) lookup(path []string) objects.BlObject {
+    obj, ok := c.globals[path[0]]
+    if !ok {
+        obj, ok = blue.GetModuleMap()["builtins"].Locals[path[0]]
+        if !ok {
+            return nil
+        }
+    }
+    for _, name := range path[1:] {
+        typeobj := obj.BlType()
+        if typeobj.GetMember == nil {
+            return nil
+        }
+        if obj = typeobj.GetMember(obj, name); obj == nil {
+            return nil
+        }
+    }
+    return obj
+}
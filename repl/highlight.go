@@ -0,0 +1,55 @@
+package repl
+
+import (
+    "strings"
+    "github.com/Magnus9/blue/token"
+    "github.com/Magnus9/blue/parser"
+)
+
+const (
+    colorReset   = "\033[0m"
+    colorKeyword = "\033[1;35m"
+    colorString  = "\033[32m"
+    colorNumber  = "\033[33m"
+)
+
+func tokenColor(tokenType int) string {
+    switch {
+        case tokenType == token.STRING:
+            return colorString
+        case tokenType == token.INTEGER || tokenType == token.FLOAT:
+            return colorNumber
+        case tokenType >= token.TRUE && tokenType <= token.NEW:
+            return colorKeyword
+    }
+    return ""
+}
+
+/*
+ * Colors the line being edited, using the tokens the
+ * scanner makes of it. Only set up when the terminal is
+ * a TTY.
+ */
+type highlighter struct{}
+
+func (h highlighter) Paint(line []rune, pos int) []rune {
+    str := string(line)
+    var buf strings.Builder
+    last := 0
+    for _, tok := range parser.Tokenize(str) {
+        color := tokenColor(tok.TokenType)
+        // Columns count bytes from 1.
+        start, end := tok.Col - 1, tok.EndCol - 1
+        if color == "" || tok.LineNum != 1 || start < last ||
+           end > len(str) {
+            continue
+        }
+        buf.WriteString(str[last:start])
+        buf.WriteString(color)
+        buf.WriteString(str[start:end])
+        buf.WriteString(colorReset)
+        last = end
+    }
+    buf.WriteString(str[last:])
+    return []rune(buf.String())
+}
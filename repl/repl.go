@@ -4,132 +4,130 @@ package repl
 import (
     "fmt"
     "os"
-    "os/user"
     "bytes"
     "strings"
     "github.com/Magnus9/blue/parser"
+    "github.com/Magnus9/blue/interm"
     "github.com/Magnus9/blue/objects"
     "github.com/Magnus9/blue/blue"
     "github.com/chzyer/readline"
 )
 
-/*
- * The keywords that can start a blocked stmt. This
- * works really bad since newlines aint treated
- * very nicely in the parser (very strict). Something
- * better will come along later, the goal for now was
- * to get a repl up and running. Hack for now is to
- * use the newline replacement character '\'.
+var (
+    rl       *readline.Instance
+    complete = &completer{}
+    // The home directory, empty if there is none.
+    home     string
+    // Every statement that parsed, for ':save'.
+    session  []string
+)
 
- * The goal strategy was to link the beginning keyword
- * of a statement onto a tokentype, and only recurse
- * the lines if the tokentype at the end of the stmt
- * was correct. Etc 'class' => NAME == Recurse.
+/*
+ * Read lines until they make up a whole statement. As
+ * long as the parser finds the input incomplete, i.e an
+ * open block, bracket or string, the next line is read
+ * with the '...' prompt. An interrupt throws away what
+ * was read so far. A line starting with ':' is a command
+ * and is handed back as is, without an AST.
  */
-var kwds = map[string]struct{}{
-    "def"  : struct{}{},
-    "if"   : struct{}{},
-    "while": struct{}{},
-    "class": struct{}{},
-}
-
-func recurseLines(buf *bytes.Buffer) bool {
+func readStmt() (string, *interm.Node, error, bool) {
+    var buf bytes.Buffer
+    prompt := ">> "
     for {
-        line, err := readline.Line(">>> ")
-        if err != nil {
-            return false
-        }
-        if len(line) == 0 {
-            continue
-        }
-        readline.AddHistory(line)
-
-        buf.WriteByte('\n')
-        buf.WriteString(line)
-        /*
-         * Maybe we have to recurse even further if we
-         * find another stmt that yields a block.
-         */
-        list := strings.Fields(line)
-        _, ok := kwds[list[0]]
-        if ok {
-            ok = recurseLines(buf)
-            if !ok {
-                return false
-            }
+        rl.SetPrompt(prompt)
+        line, err := rl.Readline()
+        if err == readline.ErrInterrupt {
+            buf.Reset()
+            prompt = ">> "
             continue
         }
-        /*
-         * Check for the keyword that can end the block.
-         * For now we only have 'end' for all blocks, so we
-         * just have to check for that by iterating the list
-         * we declared above.
-         */
-        for _, s := range list {
-            if s == "end" {
-                return true
-            }
-        }
-    }
-}
-
-func readLine() (string, bool) {
-    var buf bytes.Buffer
-    for {
-        line, err := readline.Line(">> ")
         if err != nil {
-            goto errv
+            return "", nil, nil, false
         }
-        if len(line) == 0 {
+        if buf.Len() == 0 && len(strings.TrimSpace(line)) == 0 {
             continue
         }
-        readline.AddHistory(line)
+        rl.SaveHistory(line)
 
+        if buf.Len() == 0 && strings.HasPrefix(line, ":") {
+            return line, nil, nil, true
+        }
+        if buf.Len() > 0 {
+            buf.WriteByte('\n')
+        }
         buf.WriteString(line)
-        _, ok := kwds[strings.Fields(line)[0]]
-        if ok {
-            ok = recurseLines(&buf)
-            if !ok {
-                goto errv
-            }
+        ast, err := parser.ParseFromRepl("repl", buf.String())
+        if parser.IsIncomplete(err) {
+            prompt = "... "
+            continue
         }
-        return buf.String(), true
+        return buf.String(), ast, err, true
     }
-errv:
-    return "", false
 }
 
-func Run(globals map[string]objects.BlObject) {
+/*
+ * Call 'f' and report the error it dies with, if any.
+ * Runtime errors unwind the interpreter with a panic.
+ */
+func protect(f func()) {
     defer func() {
         err := recover()
         if err != nil {
             fmt.Fprintf(os.Stderr, "%s\n", err)
-            /*
-             * Jump back into 'Run' again to process
-             * the next stmt.
-             */
-            Run(globals)
         }
     }()
+    f()
+}
+
+func Run(globals map[string]objects.BlObject) {
+    complete.globals = globals
+    if home != "" {
+        protect(func() {
+            loadFile(home + "/.bluerc", globals, true)
+        })
+    }
     for {
-        program, ok := readLine()
+        program, ast, err, ok := readStmt()
         if !ok {
             break
         }
-        ast := parser.ParseFromRepl("repl", program)
-        runtime := blue.New("repl", ast)
-        runtime.Run(globals)
+        if ast == nil && err == nil {
+            protect(func() {
+                runCommand(program, globals)
+            })
+            continue
+        }
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%s\n", err)
+            continue
+        }
+        session = append(session, program)
+        protect(func() {
+            runtime := blue.New("repl", ast)
+            runtime.Run(globals)
+        })
     }
+    rl.Close()
 }
 
 func Init() {
-    us, err := user.Current()
+    config := &readline.Config{
+        AutoComplete          : complete,
+        DisableAutoSaveHistory: true,
+    }
+    // Empty if there is no home, e.g $HOME is unset.
+    home, _ = os.UserHomeDir()
+    if len(home) > 0 {
+        config.HistoryFile = home + "/.blue_hist"
+    }
+    // Escape codes would end up in pipes and files.
+    if readline.DefaultIsTerminal() {
+        config.Painter = highlighter{}
+    }
+    var err error
+    rl, err = readline.NewEx(config)
     if err != nil {
         panic(err)
     }
     fmt.Println("Blue interactive shell")
-    if len(us.HomeDir) == 0 {
-        return
-    }
-    readline.SetHistoryPath(us.HomeDir + "/.blue_hist")
-}
\ No newline at end of file
+}
@@ -0,0 +1,45 @@
+package token
+
+import "strings"
+
+/*
+ * Quote a source line with the columns col up to endCol
+ * marked below it, the way compilers do:
+ *
+ *    x = a + foo(b)
+ *            ^~~~~~
+ *
+ * Columns count from 1 and endCol is the column after
+ * the last one marked. The indentation of the line is
+ * dropped and both lines start with 'prefix'.
+ */
+func Caret(prefix, line string, col, endCol int) string {
+    line = strings.TrimRight(line, "\r\n")
+    text := strings.TrimLeft(line, " \t")
+    indent := len(line) - len(text)
+    if col <= indent || col > len(line) + 1 {
+        return prefix + text
+    }
+    start := col - 1 - indent
+    width := endCol - col
+    if width < 1 {
+        width = 1
+    }
+    if start + width > len(text) && start < len(text) {
+        width = len(text) - start
+    }
+    var buf strings.Builder
+    buf.WriteString(prefix + text + "\n" + prefix)
+    // Keep tabs so the marker lines up with the text.
+    for i := 0; i < start; i++ {
+        if text[i] == '\t' {
+            buf.WriteByte('\t')
+        } else {
+            buf.WriteByte(' ')
+        }
+    }
+    buf.WriteByte('^')
+    buf.WriteString(strings.Repeat("~", width - 1))
+
+    return buf.String()
+}
@@ -6,14 +6,34 @@ type Token struct {
     Line      string
     TokenType int
     LineNum   int
+    // Columns of the first character and the one after
+    // the last, counting from 1.
+    Col       int
+    EndCol    int
 }
 
 func New(str string, line string,
-         tokenType, lineNum int) Token {
+         tokenType, lineNum, col, endCol int) Token {
     return Token{
         Str       : str,
         Line      : line,
         TokenType : tokenType,
         LineNum   : lineNum,
+        Col       : col,
+        EndCol    : endCol,
     }
-}
\ No newline at end of file
+}
+/*
+ * A comment kept by the scanner as trivia. Comments never
+ * reach the parser, they are only collected so tooling like
+ * the formatter can put them back into the source.
+ */
+type Comment struct {
+    Text       string
+    LineNum    int
+    EndLineNum int
+    // Set if code precedes the comment on its line.
+    Trailing   bool
+    // Set for '===' long comments.
+    Long       bool
+}
@@ -14,9 +14,9 @@ const (
 
     // NON ASSIGNING SYMBOLS
     LT; LTEQ; GT; GTEQ; LEFTSHIFT; RIGHTSHIFT; DOT
-    DOTDOT; PLUS; MINUS; STAR; SLASH; PERCENT
-    BANG; TILDE; LPAREN; RPAREN; LBRACK; RBRACK
-    LBRACE; RBRACE; COMMA; SEMICOLON; EQEQ
+    DOTDOT; DOTDOTEQ; PLUS; MINUS; STAR; SLASH; SLASHSLASH
+    PERCENT; STARSTAR; BANG; TILDE; LPAREN; RPAREN; LBRACK
+    RBRACK; LBRACE; RBRACE; COMMA; SEMICOLON; EQEQ
     BANGEQ; PIPE; PIPEPIPE; AMP; AMPAMP; CARET; EQGT
     NEWLINE; COLON
     
@@ -29,14 +29,14 @@ const (
     NAME; EOF
 
     // IMAGINARY TOKENS
-    BLOCK; LIST; HASH; HASH_ELEM; CALL; MAKE_CLASS
+    BLOCK; LIST; HASH; HASH_ELEM; SET; TUPLE; INTERP; CALL; MAKE_CLASS
     SUBSCRIPT; NEGATE; AUGASSIGN; COMP_OP; MAKE_FUNC
     MAKE_INSTANCE; PATH; SLICE
 
-    CLASSBLOCK; PARAMETERS; ARGUMENTS; LE; GE; MEMBER
-    RANGE; ADD; SUB; MUL; DIV; MODULO; COMPL; ASSIGN
-    NE; LOGICAL_OR; LOGICAL_AND; BITWISE_OR; BITWISE_AND
-    XOR; NOT
+    CLASSBLOCK; PARAMETERS; ARGUMENTS; KWARG; LE; GE; MEMBER
+    RANGE; ADD; SUB; MUL; DIV; MODULO; FLOORDIV; POWER; COMPL
+    ASSIGN; NE; LOGICAL_OR; LOGICAL_AND; BITWISE_OR; BITWISE_AND
+    XOR; NOT; NOT_IN; IS; IS_NOT
 
     ASS_BITWISE_OR; ASS_BITWISE_AND; ASS_XOR; ASS_LEFTSHIFT
     ASS_RIGHTSHIFT; ASS_ADD; ASS_SUB; ASS_MUL; ASS_DIV